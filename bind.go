@@ -0,0 +1,273 @@
+package rox
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Binding sources, which are also the struct tags read by Bind
+const (
+	BindPath   = "path"
+	BindQuery  = "query"
+	BindForm   = "form"
+	BindHeader = "header"
+	BindJSON   = "json"
+)
+
+// FieldError describes a request field which could not be bound or is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+}
+
+// BindError collects the errors of all the fields which could not be bound
+type BindError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *BindError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Source+" "+fe.Field+": "+fe.Message)
+	}
+	return "bind: " + strings.Join(msgs, "; ")
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+// Bind fills the struct pointed to by dst from the request.
+// A JSON body is decoded first, into the fields with a json tag or without any other binding tag,
+// so that it cannot set path, query, form or header fields. Then fields are set from their tags:
+//
+//	type UserReq struct {
+//		ID     int                   `path:"id"`
+//		Page   int                   `query:"page"`
+//		Email  string                `form:"email"`
+//		Avatar *multipart.FileHeader `form:"avatar"`
+//		Tenant string                `header:"X-Tenant"`
+//		Name   string                `json:"name"`
+//	}
+//
+// Fields may be strings, bools, numbers, encoding.TextUnmarshalers (eg time.Time, rox.UUID),
+// pointers to or slices of those, and *multipart.FileHeader for form files.
+// Fields whose value is absent from the request are left untouched.
+// Conversion failures are collected into a *BindError.
+func Bind(ctx *fasthttp.RequestCtx, params Params, dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("bind: dst must be a non-nil pointer to a struct")
+	}
+
+	b := binder{ctx: ctx, params: params}
+	if body := ctx.PostBody(); len(body) > 0 && isJSON(ctx.Request.Header.ContentType()) {
+		decoded := reflect.New(rv.Elem().Type())
+		decoded.Elem().Set(rv.Elem())
+		err := json.Unmarshal(body, decoded.Interface())
+		copyJSONFields(rv.Elem(), decoded.Elem())
+		if err != nil {
+			fe := FieldError{Source: BindJSON, Message: err.Error()}
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				fe.Field, fe.Message = typeErr.Field, "invalid "+typeErr.Value+" for "+typeErr.Type.String()
+			}
+			b.errs = append(b.errs, fe)
+		}
+	}
+	if isMultipart(ctx.Request.Header.ContentType()) {
+		if form, err := ctx.MultipartForm(); err == nil {
+			b.form = form
+		} else {
+			b.errs = append(b.errs, FieldError{Source: BindForm, Message: err.Error()})
+		}
+	}
+
+	b.bindStruct(rv.Elem())
+	if len(b.errs) > 0 {
+		return &BindError{Errors: b.errs}
+	}
+	return nil
+}
+
+// copyJSONFields copies from src to dst the fields a JSON body may set,
+// those with a json tag or without any other binding tag
+func copyJSONFields(dst, src reflect.Value) {
+	st := dst.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf, fv := st.Field(i), dst.Field(i)
+		if _, tagged := sf.Tag.Lookup(BindJSON); sf.Anonymous && sf.Type.Kind() == reflect.Struct && !tagged {
+			copyJSONFields(fv, src.Field(i)) // embedded fields are promoted in JSON
+			continue
+		}
+		if fv.CanSet() && jsonBindable(sf) {
+			fv.Set(src.Field(i))
+		}
+	}
+}
+
+// jsonBindable reports whether a JSON body may set the field
+func jsonBindable(sf reflect.StructField) bool {
+	if _, ok := sf.Tag.Lookup(BindJSON); ok {
+		return true
+	}
+	for _, source := range [...]string{BindPath, BindQuery, BindForm, BindHeader} {
+		if _, ok := sf.Tag.Lookup(source); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// binder holds the state of a single Bind call
+type binder struct {
+	ctx    *fasthttp.RequestCtx
+	params Params
+	form   *multipart.Form
+	errs   []FieldError
+}
+
+func (b *binder) bindStruct(sv reflect.Value) {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf, fv := st.Field(i), sv.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			b.bindStruct(fv)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		for _, source := range [...]string{BindPath, BindQuery, BindForm, BindHeader} {
+			name, ok := sf.Tag.Lookup(source)
+			if !ok || name == "" || name == "-" {
+				continue
+			}
+			if source == BindForm && sf.Type == fileHeaderType {
+				if b.form != nil && len(b.form.File[name]) > 0 {
+					fv.Set(reflect.ValueOf(b.form.File[name][0]))
+				}
+				continue
+			}
+			values := b.values(source, name)
+			if len(values) == 0 {
+				continue
+			}
+			if err := setField(fv, values); err != nil {
+				b.errs = append(b.errs, FieldError{Field: name, Source: source, Message: err.Error()})
+			}
+		}
+	}
+}
+
+// values returns the request values of the named field from the given source
+func (b *binder) values(source, name string) (values []string) {
+	switch source {
+	case BindPath:
+		if v, err := b.params.lookup(name); err == nil {
+			values = append(values, v)
+		}
+	case BindQuery:
+		for _, v := range b.ctx.QueryArgs().PeekMulti(name) {
+			values = append(values, string(v))
+		}
+	case BindForm:
+		if b.form != nil {
+			return b.form.Value[name]
+		}
+		for _, v := range b.ctx.PostArgs().PeekMulti(name) {
+			values = append(values, string(v))
+		}
+	case BindHeader:
+		if v := b.ctx.Request.Header.Peek(name); v != nil {
+			values = append(values, string(v))
+		}
+	}
+	return
+}
+
+// setField converts the values into the field
+func setField(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setValue(slice.Index(i), v); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setValue(fv, values[0])
+}
+
+// setValue converts a single value into v
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("invalid %s value %q", v.Type(), s)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool value %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q", v.Kind(), s)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q", v.Kind(), s)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q", v.Kind(), s)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+func isJSON(contentType []byte) bool {
+	return bytes.HasPrefix(contentType, []byte(ContentTypeJson))
+}
+
+func isMultipart(contentType []byte) bool {
+	return bytes.HasPrefix(contentType, []byte("multipart/form-data"))
+}
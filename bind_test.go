@@ -0,0 +1,260 @@
+package rox
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type bindTestReq struct {
+	ID      int       `path:"id"`
+	Page    int       `query:"page"`
+	Tags    []string  `query:"tag"`
+	Since   time.Time `query:"since"`
+	Email   string    `form:"email"`
+	Tenant  string    `header:"X-Tenant"`
+	Name    string    `json:"name"`
+	Active  *bool     `json:"active"`
+	Account UUID      `query:"account"`
+}
+
+func TestBind(t *testing.T) {
+	r := New()
+	r.Post("/users/:id|int", func(ctx *fasthttp.RequestCtx, params Params) {
+		var req bindTestReq
+		if err := Bind(ctx, params, &req); err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		_, _ = ctx.WriteString(fmt.Sprintf("id=%d page=%d tags=%v since=%s tenant=%s name=%s active=%v account=%s",
+			req.ID, req.Page, req.Tags, req.Since.Format(DateLayout), req.Tenant, req.Name, *req.Active, req.Account))
+	})
+
+	req := httptest.NewRequest("POST",
+		"/users/42?page=3&tag=a&tag=b&since=2024-02-29T00:00:00Z&account=6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		strings.NewReader(`{"name": "Sue", "active": true}`))
+	req.Header.Set(HeaderContentType, ContentTypeJson)
+	req.Header.Set("X-Tenant", "acme")
+
+	resp, err := TestRunner(r, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "id=42 page=3 tags=[a b] since=2024-02-29 tenant=acme name=Sue active=true " +
+		"account=6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	if bd := string(resp.Body()); !strings.Contains(bd, want) {
+		t.Errorf("!! Got body: %s,\nShould contain: %s", bd, want)
+	}
+}
+
+func TestBindForm(t *testing.T) {
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.Header.SetContentType("application/x-www-form-urlencoded")
+	ctx.Request.SetBodyString("email=sue%40example.com")
+
+	var req bindTestReq
+	if err := Bind(&ctx, Params{}, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Email != "sue@example.com" {
+		t.Errorf("Email = %q, want %q", req.Email, "sue@example.com")
+	}
+}
+
+func TestBindJSONCannotSetOtherSources(t *testing.T) {
+	type embedded struct {
+		Role string `header:"X-Role"`
+		Note string
+	}
+	type spoofReq struct {
+		embedded
+		ID     int    `path:"id"`
+		Tenant string `header:"X-Tenant"`
+		Name   string `json:"name"`
+		Title  string
+	}
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetContentType(ContentTypeJson)
+	ctx.Request.SetBodyString(`{"id": 7, "tenant": "evil", "role": "admin", "note": "hi", "name": "Sue", "title": "Dr"}`)
+
+	var req spoofReq
+	if err := Bind(&ctx, Params{}, &req); err != nil {
+		t.Fatal(err)
+	}
+	want := spoofReq{embedded: embedded{Note: "hi"}, Name: "Sue", Title: "Dr"}
+	if req != want {
+		t.Errorf("Bind() = %+v, want %+v", req, want)
+	}
+
+	// Validated binds the same way
+	r := New()
+	r.Post("/tenant", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("tenant=" + Bound[spoofReq](ctx).Tenant)
+	}, Validated[spoofReq]())
+	hreq := httptest.NewRequest("POST", "/tenant", strings.NewReader(`{"tenant": "evil"}`))
+	hreq.Header.Set(HeaderContentType, ContentTypeJson)
+	if resp := readResponse(t, r, nil, hreq); string(resp.Body()) != "tenant=" {
+		t.Errorf("Validated bound %q, want an empty tenant", resp.Body())
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetRequestURI("/users?page=two&since=yesterday")
+	ctx.Request.Header.SetContentType(ContentTypeJson)
+	ctx.Request.SetBodyString(`{"name": 7}`)
+
+	var req bindTestReq
+	err := Bind(&ctx, Params{}, &req)
+
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("Bind() error = %v, want a *BindError", err)
+	}
+	fields := map[string]string{}
+	for _, fe := range bindErr.Errors {
+		fields[fe.Field] = fe.Source
+	}
+	for field, source := range map[string]string{"name": BindJSON, "page": BindQuery, "since": BindQuery} {
+		if fields[field] != source {
+			t.Errorf("missing %s error for field %q in %v", source, field, bindErr.Errors)
+		}
+	}
+
+	if err := Bind(&ctx, Params{}, req); err == nil {
+		t.Errorf("Bind() with a non-pointer dst should fail, got %v", err)
+	}
+}
+
+type validateTestReq struct {
+	Email   string   `json:"email" validate:"required,email"`
+	Age     int      `json:"age" validate:"min=18,max=130"`
+	Plan    string   `json:"plan" validate:"oneof=free pro"`
+	Tags    []string `json:"tags" validate:"max=2"`
+	Code    string   `query:"code" validate:"len=4"`
+	Comment *string  `json:"comment" validate:"min=3"`
+	Address struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+}
+
+func TestValidate(t *testing.T) {
+	valid := validateTestReq{Email: "sue@example.com", Age: 30, Plan: "pro", Code: "abcd"}
+	valid.Address.City = "Paris"
+	if err := Validate(valid); err != nil {
+		t.Fatalf("Validate() of a valid struct error = %v", err)
+	}
+
+	short := "no"
+	invalid := validateTestReq{Email: "sue", Age: 12, Plan: "gold", Tags: []string{"a", "b", "c"},
+		Code: "abc", Comment: &short}
+	err := Validate(&invalid)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+	}
+	got := map[string]bool{}
+	for _, fe := range validationErr.Errors {
+		got[fe.Field] = true
+	}
+	for _, field := range []string{"email", "age", "plan", "tags", "code", "comment", "address.city"} {
+		if !got[field] {
+			t.Errorf("missing error for field %q in %v", field, validationErr.Errors)
+		}
+	}
+
+	type badRule struct {
+		Name string `validate:"uppercase"`
+	}
+	if err := Validate(badRule{}); err == nil || errors.As(err, &validationErr) {
+		t.Errorf("Validate() with an unknown rule error = %v, want a plain error", err)
+	}
+}
+
+func TestValidated(t *testing.T) {
+	r := New()
+	r.Post("/signup", func(ctx *fasthttp.RequestCtx, params Params) {
+		req := Bound[validateTestReq](ctx)
+		_, _ = ctx.WriteString("Welcome " + req.Email)
+	}, Validated[validateTestReq]())
+
+	tests := []struct {
+		name     string
+		body     string
+		contains []string
+	}{
+		{name: "Valid", body: `{"email": "sue@example.com", "age": 30, "plan": "free", "address": {"city": "Paris"}}`,
+			contains: []string{"200 OK", "Welcome sue@example.com"}},
+		{name: "Invalid", body: `{"email": "sue", "age": 30, "plan": "free", "address": {"city": "Paris"}}`,
+			contains: []string{"422 Unprocessable Entity", `{"field":"email","message":"must be a valid email address"}`}},
+		{name: "Unbindable", body: `{"email": "sue@example.com", "age": "thirty"}`,
+			contains: []string{"400 Bad Request", `"field":"age","source":"json"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/signup?code=abcd", strings.NewReader(tt.body))
+			req.Header.Set(HeaderContentType, ContentTypeJson)
+			resp, err := TestRunner(r, nil, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.contains {
+				if bd := string(resp.Body()); !strings.Contains(bd, want) {
+					t.Errorf("!! Got body: %s,\nShould contain: %s", bd, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatedErrorHandler(t *testing.T) {
+	errorHandler := func(name string) ErrorHandler {
+		return func(ctx *fasthttp.RequestCtx, err error) {
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				ctx.Error(name+": invalid "+validationErr.Errors[0].Field, fasthttp.StatusBadRequest)
+				return
+			}
+			ctx.Error(name+": "+err.Error(), fasthttp.StatusInternalServerError)
+		}
+	}
+	signup := func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Welcome " + Bound[validateTestReq](ctx).Email)
+	}
+
+	r := New(Options{CustomErrorHandler: errorHandler("app")})
+	r.Post("/signup", signup, Validated[validateTestReq]())
+	api := New() // falls back to the error handler of r
+	api.Post("/signup", signup, Validated[validateTestReq]())
+	r.Mount("/api", api)
+	partners := New(Options{CustomErrorHandler: errorHandler("partners")})
+	partners.Post("/signup", signup, Validated[validateTestReq]())
+	api.Mount("/partners", partners)
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/signup", fasthttp.StatusBadRequest, "app: invalid email"},
+		{"/api/signup", fasthttp.StatusBadRequest, "app: invalid email"},
+		{"/api/partners/signup", fasthttp.StatusBadRequest, "partners: invalid email"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.target, strings.NewReader(`{"email": "sue", "age": 30, "plan": "free"}`))
+		req.Header.Set(HeaderContentType, ContentTypeJson)
+		resp := readResponse(t, r, fsvr, req)
+		if resp.StatusCode() != tt.wantStatus || string(resp.Body()) != tt.wantBody {
+			t.Errorf("POST %s: status %d, body %q, want %d %q", tt.target, resp.StatusCode(), resp.Body(), tt.wantStatus, tt.wantBody)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package rox
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ErrHandler handles HTTP requests, leaving failures to the router's error handler.
+// Register it with ApiErr or the shortcuts such as GetErr, or convert it to a Handler with HandleErr
+type ErrHandler func(ctx *fasthttp.RequestCtx, params Params) error
+
+// ErrorHandler writes the response for an error returned by an ErrHandler
+type ErrorHandler func(ctx *fasthttp.RequestCtx, err error)
+
+// HTTPError is an error carrying the HTTP status code of the response
+type HTTPError struct {
+	Code int
+	Msg  string
+}
+
+// Error returns Msg, or else the status text of Code
+func (e HTTPError) Error() string {
+	if e.Msg == "" {
+		return http.StatusText(e.Code)
+	}
+	return e.Msg
+}
+
+// Errors for common failures, to be returned as is or wrapped
+// Example:
+//
+//	return fmt.Errorf("user %d: %w", id, rox.ErrNotFound)
+var (
+	ErrBadRequest   = HTTPError{Code: fasthttp.StatusBadRequest, Msg: "bad request"}
+	ErrUnauthorized = HTTPError{Code: fasthttp.StatusUnauthorized, Msg: "unauthorized"}
+	ErrForbidden    = HTTPError{Code: fasthttp.StatusForbidden, Msg: "forbidden"}
+	ErrNotFound     = HTTPError{Code: fasthttp.StatusNotFound, Msg: "not found"}
+	ErrConflict     = HTTPError{Code: fasthttp.StatusConflict, Msg: "conflict"}
+)
+
+// HandleErr converts an ErrHandler into a Handler.
+// Errors are handled by Options.CustomErrorHandler, or else by DefaultErrorHandler
+func (r *Rox) HandleErr(handler ErrHandler) Handler {
+	if handler == nil {
+		panic("router: nil handler")
+	}
+	return func(ctx *fasthttp.RequestCtx, params Params) {
+		if err := handler(ctx, params); err != nil {
+			r.handleError(ctx, err)
+		}
+	}
+}
+
+// ApiErr registers an api whose handler returns an error.
+// See Api and HandleErr
+func (r *Rox) ApiErr(method string, pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return r.Api(method, pattern, r.HandleErr(handler), mws...)
+}
+
+// ApiErr registers an api under the group prefix whose handler returns an error.
+// See Rox.ApiErr
+func (g *Group) ApiErr(method string, pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return g.Api(method, pattern, g.rox.HandleErr(handler), mws...)
+}
+
+// GetErr is a shortcut for ApiErr(http.MethodGet, pattern, handler)
+func (r *Rox) GetErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return r.ApiErr(http.MethodGet, pattern, handler, mws...)
+}
+
+// PostErr is a shortcut for ApiErr(http.MethodPost, pattern, handler)
+func (r *Rox) PostErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return r.ApiErr(http.MethodPost, pattern, handler, mws...)
+}
+
+// GetPostErr sets Get and Post methods for pattern with an error returning handler
+func (r *Rox) GetPostErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	r.ApiErr(http.MethodGet, pattern, handler, mws...)
+	return r.ApiErr(http.MethodPost, pattern, handler, mws...)
+}
+
+// PutErr is a shortcut for ApiErr(http.MethodPut, pattern, handler)
+func (r *Rox) PutErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return r.ApiErr(http.MethodPut, pattern, handler, mws...)
+}
+
+// DeleteErr is a shortcut for ApiErr(http.MethodDelete, pattern, handler)
+func (r *Rox) DeleteErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return r.ApiErr(http.MethodDelete, pattern, handler, mws...)
+}
+
+// HeadErr is a shortcut for ApiErr(http.MethodHead, pattern, handler)
+func (r *Rox) HeadErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return r.ApiErr(http.MethodHead, pattern, handler, mws...)
+}
+
+// MethodOptionsErr is a shortcut for ApiErr(http.MethodOptions, pattern, handler)
+func (r *Rox) MethodOptionsErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return r.ApiErr(http.MethodOptions, pattern, handler, mws...)
+}
+
+// PatchErr is a shortcut for ApiErr(http.MethodPatch, pattern, handler)
+func (r *Rox) PatchErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return r.ApiErr(http.MethodPatch, pattern, handler, mws...)
+}
+
+// GetErr is a shortcut for ApiErr(http.MethodGet, pattern, handler)
+func (g *Group) GetErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return g.ApiErr(http.MethodGet, pattern, handler, mws...)
+}
+
+// PostErr is a shortcut for ApiErr(http.MethodPost, pattern, handler)
+func (g *Group) PostErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return g.ApiErr(http.MethodPost, pattern, handler, mws...)
+}
+
+// GetPostErr sets Get and Post methods for pattern with an error returning handler
+func (g *Group) GetPostErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	g.ApiErr(http.MethodGet, pattern, handler, mws...)
+	return g.ApiErr(http.MethodPost, pattern, handler, mws...)
+}
+
+// PutErr is a shortcut for ApiErr(http.MethodPut, pattern, handler)
+func (g *Group) PutErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return g.ApiErr(http.MethodPut, pattern, handler, mws...)
+}
+
+// DeleteErr is a shortcut for ApiErr(http.MethodDelete, pattern, handler)
+func (g *Group) DeleteErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return g.ApiErr(http.MethodDelete, pattern, handler, mws...)
+}
+
+// HeadErr is a shortcut for ApiErr(http.MethodHead, pattern, handler)
+func (g *Group) HeadErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return g.ApiErr(http.MethodHead, pattern, handler, mws...)
+}
+
+// MethodOptionsErr is a shortcut for ApiErr(http.MethodOptions, pattern, handler)
+func (g *Group) MethodOptionsErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return g.ApiErr(http.MethodOptions, pattern, handler, mws...)
+}
+
+// PatchErr is a shortcut for ApiErr(http.MethodPatch, pattern, handler)
+func (g *Group) PatchErr(pattern string, handler ErrHandler, mws ...MiddleWare) *RouteRef {
+	return g.ApiErr(http.MethodPatch, pattern, handler, mws...)
+}
+
+// routerKey is the user value key of the router owning the matched route
+type routerKey struct{}
+
+// routeOwner returns the router the matched route was registered on,
+// or r if the request was not routed yet
+func (r *Rox) routeOwner(ctx *fasthttp.RequestCtx) *Rox {
+	if owner, ok := ctx.UserValue(routerKey{}).(*Rox); ok && owner != nil {
+		return owner
+	}
+	return r
+}
+
+// handleRouteError hands err to the error handler of the router owning the matched route
+func handleRouteError(ctx *fasthttp.RequestCtx, err error) {
+	if owner, ok := ctx.UserValue(routerKey{}).(*Rox); ok && owner != nil {
+		owner.handleError(ctx, err)
+		return
+	}
+	DefaultErrorHandler(ctx, err)
+}
+
+// handleError hands err to the first custom error handler of this router
+// or the routers it is mounted in
+func (r *Rox) handleError(ctx *fasthttp.RequestCtx, err error) {
+	for rx := r; rx != nil; rx = rx.parent {
+		if rx.Options.CustomErrorHandler != nil {
+			rx.Options.CustomErrorHandler(ctx, err)
+			return
+		}
+	}
+	DefaultErrorHandler(ctx, err)
+}
+
+// DefaultErrorHandler writes a JSON error response:
+//   - a Problem is written as an application/problem+json document,
+//   - an HTTPError gets its Code, or 500 if zero, and {"error": Msg},
+//   - a *BindError gets a 400 and a *ValidationError a 422 with the list of field errors,
+//   - any other error is logged and gets a 500.
+func DefaultErrorHandler(ctx *fasthttp.RequestCtx, err error) {
+	var httpErr HTTPError
+	var httpErrPtr *HTTPError
+	var bindErr *BindError
+	var validationErr *ValidationError
+
+	if p, ok := problemFrom(err); ok {
+		if p.Status >= fasthttp.StatusInternalServerError {
+			log.Println("Handler error:", string(ctx.Method()), string(ctx.Path()), "-", err)
+		}
+		WriteProblem(ctx, p)
+		return
+	}
+
+	switch {
+	case errors.As(err, &httpErr):
+	case errors.As(err, &httpErrPtr):
+		httpErr = *httpErrPtr
+	case errors.As(err, &bindErr), errors.As(err, &validationErr):
+		writeFieldErrors(ctx, err)
+		return
+	default:
+		httpErr = HTTPError{Code: fasthttp.StatusInternalServerError}
+	}
+	if httpErr.Code == 0 { // no status given
+		httpErr.Code = fasthttp.StatusInternalServerError
+	}
+	if httpErr.Code >= fasthttp.StatusInternalServerError {
+		log.Println("Handler error:", string(ctx.Method()), string(ctx.Path()), "-", err)
+	}
+
+	writeJSON(ctx, httpErr.Code, errorBody{Error: httpErr.Error()})
+}
+
+// errorBody is the JSON body of simple error responses
+type errorBody struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,231 @@
+package rox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CacheControlImmutable is the Cache-Control of content-hashed static files
+const CacheControlImmutable = "public, max-age=31536000, immutable"
+
+const fingerprintLen = 8 // hex digits of the content hash in file names
+
+// Asset returns the content-hashed URL path of a static file of a route with
+// StaticOpts.Fingerprint or StaticOpts.Manifest, or else the path unchanged.
+// It is meant for templates, once the server is prepared.
+// The files of a mounted router are looked up with its mount prefix, which the hashed URL path includes
+// Example:
+//
+//	<link rel="stylesheet" href="{{ asset "/css/sample.css" }}">
+//	// with template.FuncMap{"asset": r.Asset} renders /css/sample.3f9a1c2e.css
+func (r *Rox) Asset(urlPath string) string {
+	if hashed, ok := r.assets[urlPath]; ok {
+		return hashed
+	}
+	// Mounted routes are fingerprinted by the router they are merged into
+	if r.parent != nil {
+		for _, m := range r.parent.mounts {
+			if m.sub != r {
+				continue
+			}
+			if hashed := r.parent.Asset(m.prefix + urlPath); hashed != m.prefix+urlPath {
+				return hashed
+			}
+		}
+	}
+	return urlPath
+}
+
+// fingerprint hashes the files of, or reads the manifest of, the static route,
+// and wraps its file handler to serve the content-hashed URLs.
+// On failure, the error is logged and the files are served without content-hashed URLs
+func (r *Rox) fingerprint(ap *AssetPath, handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	assets, err := ap.fingerprints()
+	if err != nil {
+		log.Println("Cannot fingerprint static route", string(ap.Prefix), "-", err)
+		return handler
+	}
+
+	ap.hashed = make(map[string]string, len(assets))
+	if r.assets == nil {
+		r.assets = make(map[string]string, len(assets))
+	}
+	for urlPath, hashedPath := range assets {
+		r.assets[urlPath] = hashedPath
+		if ap.Opts.Manifest != "" { // the hashed copies are files
+			ap.hashed[hashedPath] = hashedPath
+		} else {
+			ap.hashed[hashedPath] = urlPath
+		}
+	}
+
+	var checker *hashChecker // the hashed copies of a manifest are files of their own
+	if ap.Opts.Manifest == "" {
+		fsys, urlPrefix, _ := ap.files()
+		checker = &hashChecker{fsys: fsys, urlPrefix: urlPrefix, stamps: make(map[string]fileStamp)}
+	}
+	return fingerprintHandler(handler, ap.hashed, checker)
+}
+
+// fingerprintHandler serves content-hashed URLs, with immutable caching, from the files mapped by hashed.
+// With a checker, a hashed URL whose file no longer has that hash is not found
+func fingerprintHandler(handler fasthttp.RequestHandler, hashed map[string]string, checker *hashChecker) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		hashedPath := string(ctx.Path())
+		urlPath, ok := hashed[hashedPath]
+		if !ok {
+			handler(ctx)
+			return
+		}
+		if checker != nil && !checker.matches(urlPath, hashedPath) {
+			writeRouterStatus(ctx, fasthttp.StatusNotFound, "")
+			return
+		}
+
+		uri := ctx.URI()
+		uri.SetPath(urlPath)
+		handler(ctx)
+		uri.SetPath(hashedPath)
+
+		switch ctx.Response.StatusCode() {
+		case fasthttp.StatusOK, fasthttp.StatusPartialContent, fasthttp.StatusNotModified:
+			ctx.Response.Header.Set("Cache-Control", CacheControlImmutable)
+		}
+	}
+}
+
+// fileStamp identifies a version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// hashChecker checks that fingerprinted files were not changed since they were hashed
+type hashChecker struct {
+	fsys      fs.FS
+	urlPrefix string
+	mu        sync.Mutex
+	stamps    map[string]fileStamp // versions of the files found to still have their hash
+}
+
+// matches reports whether the file at urlPath still has the hash of its content-hashed url path.
+// The file is hashed again only when its modification time or size changed
+func (hc *hashChecker) matches(urlPath, hashedPath string) bool {
+	name := strings.TrimPrefix(urlPath, hc.urlPrefix)
+	fi, err := fs.Stat(hc.fsys, name)
+	if err != nil {
+		return false
+	}
+	stamp := fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+
+	hc.mu.Lock()
+	known, ok := hc.stamps[name]
+	hc.mu.Unlock()
+	if ok && known.modTime.Equal(stamp.modTime) && known.size == stamp.size {
+		return true
+	}
+
+	// hashedName inserts the hash before the extension of the original name
+	hash := strings.TrimSuffix(hashedPath, path.Ext(urlPath))
+	hash = hash[len(hash)-fingerprintLen:]
+	if current, err := hashFile(hc.fsys, name); err != nil || current != hash {
+		return false
+	}
+	hc.mu.Lock()
+	hc.stamps[name] = stamp
+	hc.mu.Unlock()
+	return true
+}
+
+// fingerprints maps the url paths of the files of the static route to their content-hashed url paths
+func (ap *AssetPath) fingerprints() (map[string]string, error) {
+	fsys, urlPrefix, err := ap.files()
+	if err != nil {
+		return nil, err
+	}
+
+	assets := make(map[string]string)
+	if ap.Opts.Manifest != "" {
+		data, err := fs.ReadFile(fsys, ap.Opts.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read manifest - %w", err)
+		}
+		var manifest map[string]string
+		if err = json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("cannot parse manifest %s - %w", ap.Opts.Manifest, err)
+		}
+		for name, hashedName := range manifest {
+			urlPath := urlPrefix + strings.TrimPrefix(name, "/")
+			if strings.HasPrefix(urlPath, string(ap.Prefix)) {
+				assets[urlPath] = urlPrefix + strings.TrimPrefix(hashedName, "/")
+			}
+		}
+		return assets, nil
+	}
+
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !strings.HasPrefix(urlPrefix+name, string(ap.Prefix)) { // not served by the route
+			return nil
+		}
+		hash, err := hashFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		assets[urlPrefix+name] = urlPrefix + hashedName(name, hash)
+		return nil
+	})
+	return assets, err
+}
+
+// files returns the files of the static route and the url prefix of their names.
+// This is the part of the route prefix stripped before looking up files
+func (ap *AssetPath) files() (fsys fs.FS, urlPrefix string, err error) {
+	segments := strings.SplitAfter(strings.TrimPrefix(string(ap.Prefix), "/"), "/")
+	if ap.StripSlashes >= len(segments) {
+		return nil, "", fmt.Errorf("static route %s cannot strip %d slashes", ap.Prefix, ap.StripSlashes)
+	}
+	urlPrefix = "/" + strings.Join(segments[:ap.StripSlashes], "")
+	if !strings.HasSuffix(urlPrefix, "/") {
+		urlPrefix += "/"
+	}
+	if ap.fsys != nil {
+		return ap.fsys, urlPrefix, nil
+	}
+	return os.DirFS(ap.FileSystemRoot), urlPrefix, nil
+}
+
+// hashFile returns the hex content hash of the file
+func hashFile(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:fingerprintLen], nil
+}
+
+// hashedName inserts the hash before the extension of the file name, eg css/sample.3f9a1c2e.css
+func hashedName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
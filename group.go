@@ -0,0 +1,104 @@
+package rox
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group is a set of routes sharing a path prefix and a middleware chain.
+// Group middlewares run only for routes registered through the group,
+// after the global middlewares added with Rox.Use
+type Group struct {
+	rox         *Rox
+	prefix      string
+	middlewares []MiddleWare
+}
+
+// Group returns a sub-router which registers its routes under prefix.
+// The given middlewares apply only to routes of this group
+// Example:
+//
+//	api := r.Group("/api/v1", rox.MiddleWare{MidFunc: apiAuth, FailCode: fasthttp.StatusUnauthorized})
+//	api.Get("/users/:id", userHandler) // -> /api/v1/users/:id
+func (r *Rox) Group(prefix string, mw ...MiddleWare) *Group {
+	return &Group{rox: r, prefix: cleanPrefix(prefix), middlewares: mw}
+}
+
+// Group returns a nested group. The prefix is appended to the parent group's prefix,
+// and the parent group's middlewares run before those given here
+func (g *Group) Group(prefix string, mw ...MiddleWare) *Group {
+	mws := make([]MiddleWare, 0, len(g.middlewares)+len(mw))
+	mws = append(mws, g.middlewares...)
+	mws = append(mws, mw...)
+	return &Group{rox: g.rox, prefix: g.prefix + cleanPrefix(prefix), middlewares: mws}
+}
+
+// Prefix returns the full path prefix of the group
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// Api registers an api under the group prefix.
+// The group middlewares are run before the route's own middlewares
+func (g *Group) Api(method string, pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	all := make([]MiddleWare, 0, len(g.middlewares)+len(mws))
+	all = append(all, g.middlewares...)
+	all = append(all, mws...)
+	return g.rox.Api(method, joinPath(g.prefix, pattern), handler, all...)
+}
+
+// Get is a shortcut for Api(http.MethodGet, pattern, handler)
+func (g *Group) Get(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return g.Api(http.MethodGet, pattern, handler, mws...)
+}
+
+// Post is a shortcut for Api(http.MethodPost, pattern, handler)
+func (g *Group) Post(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return g.Api(http.MethodPost, pattern, handler, mws...)
+}
+
+// GetPost set Get and Post methods for pattern, handler)
+func (g *Group) GetPost(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	g.Api(http.MethodGet, pattern, handler, mws...)
+	return g.Api(http.MethodPost, pattern, handler, mws...)
+}
+
+// Put is a shortcut for Api(http.MethodPut, pattern, handler)
+func (g *Group) Put(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return g.Api(http.MethodPut, pattern, handler, mws...)
+}
+
+// Delete is a shortcut for Api(http.MethodDelete, pattern, handler)
+func (g *Group) Delete(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return g.Api(http.MethodDelete, pattern, handler, mws...)
+}
+
+// Head is a shortcut for Api(http.MethodHead, pattern, handler)
+func (g *Group) Head(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return g.Api(http.MethodHead, pattern, handler, mws...)
+}
+
+// MethodOptions is a shortcut for Api(http.MethodOptions, pattern, handler)
+func (g *Group) MethodOptions(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return g.Api(http.MethodOptions, pattern, handler, mws...)
+}
+
+// Patch is a shortcut for Api(http.MethodPatch, pattern, handler)
+func (g *Group) Patch(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return g.Api(http.MethodPatch, pattern, handler, mws...)
+}
+
+// cleanPrefix removes any trailing slashes from a route prefix
+// so that it can be joined with patterns, which start with a slash
+func cleanPrefix(prefix string) string {
+	return strings.TrimRight(prefix, "/")
+}
+
+// joinPath joins a cleaned prefix and a pattern.
+// The root pattern "/" of a prefix is the prefix itself
+func joinPath(prefix, pattern string) string {
+	if pattern == "/" && prefix != "" {
+		return prefix
+	}
+	return prefix + pattern
+}
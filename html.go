@@ -2,5 +2,7 @@ package rox
 
 const HeaderContentLength = "Content-Length"
 const HeaderContentType = "Content-Type"
+const HeaderAllow = "Allow"
 const ContentTypeText = "text/html"
 const ContentTypeJson = "application/json"
+const ContentTypeProblemJson = "application/problem+json"
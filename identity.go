@@ -0,0 +1,66 @@
+package rox
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net"
+	"net/url"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ClientIdent is the identity of a TLS client from its certificate
+type ClientIdent struct {
+	Subject        pkix.Name
+	DNSNames       []string // the subject alternative names
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL // eg SPIFFE IDs
+	Fingerprint    string     // hex SHA-256 of the DER certificate
+	// Verified is whether the certificate was verified against the client CAs.
+	// It is always true under ClientAuthRequire and ClientAuthVerifyIfGiven
+	Verified    bool
+	Certificate *x509.Certificate
+}
+
+// ClientIdentity returns the identity of the TLS client of the request,
+// or nil if the request is not over TLS or the client sent no certificate.
+// Under ClientAuthRequest, check Verified before trusting the identity
+// Example:
+//
+//	r.Get("/internal/jobs", func(ctx *fasthttp.RequestCtx, params rox.Params) {
+//		id := rox.ClientIdentity(ctx)
+//		log.Println("called by", id.Subject.CommonName)
+//	}, rox.RequireClientCert())
+func ClientIdentity(ctx *fasthttp.RequestCtx) *ClientIdent {
+	state := ctx.TLSConnectionState()
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := state.PeerCertificates[0]
+	sum := sha256.Sum256(cert.Raw)
+	return &ClientIdent{
+		Subject:        cert.Subject,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		IPAddresses:    cert.IPAddresses,
+		URIs:           cert.URIs,
+		Fingerprint:    hex.EncodeToString(sum[:]),
+		Verified:       len(state.VerifiedChains) > 0,
+		Certificate:    cert,
+	}
+}
+
+// RequireClientCert is a gate middleware that lets through only requests with a verified client certificate.
+// Others get a 401. It is useful on routes which need mutual TLS when TLSOpts.ClientAuth is ClientAuthVerifyIfGiven
+func RequireClientCert() MiddleWare {
+	return MiddleWare{
+		MidFunc: func(ctx *fasthttp.RequestCtx) (ok bool) {
+			id := ClientIdentity(ctx)
+			return id != nil && id.Verified
+		},
+		FailCode: fasthttp.StatusUnauthorized,
+	}
+}
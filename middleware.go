@@ -4,9 +4,17 @@ import "github.com/valyala/fasthttp"
 
 type MiddleWareFunc func(ctx *fasthttp.RequestCtx) (ok bool)
 
+// MiddleWareNextFunc is an onion style middleware which is chained around the route handler.
+// It must call next to continue the request, so it can act both before and after the handler,
+// or stop the request by not calling next
+type MiddleWareNextFunc func(ctx *fasthttp.RequestCtx, params Params, next Handler)
+
+// MiddleWare is either a gate (MidFunc with FailCode) or an onion style middleware (NextFunc).
+// When NextFunc is set, MidFunc and FailCode are ignored
 type MiddleWare struct {
 	MidFunc  MiddleWareFunc
 	FailCode int
+	NextFunc MiddleWareNextFunc
 }
 
 // Use adds a middleware function before regular routes
@@ -14,3 +22,51 @@ type MiddleWare struct {
 func (r *Rox) Use(m MiddleWareFunc, failCode int) {
 	r.middlewares = append(r.middlewares, MiddleWare{MidFunc: m, FailCode: failCode})
 }
+
+// UseNext adds an onion style middleware around the handlers of matched routes.
+// They run after all middlewares added with Use, in the order they were added
+// Example:
+//
+//	r.UseNext(func(ctx *fasthttp.RequestCtx, params rox.Params, next rox.Handler) {
+//		start := time.Now()
+//		next(ctx, params)
+//		log.Println(string(ctx.Path()), "took", time.Since(start))
+//	})
+func (r *Rox) UseNext(m MiddleWareNextFunc) {
+	r.middlewares = append(r.middlewares, MiddleWare{NextFunc: m})
+}
+
+// wrap returns the handler wrapped by the middleware
+func (mw MiddleWare) wrap(next Handler) Handler {
+	if mw.NextFunc != nil {
+		return func(ctx *fasthttp.RequestCtx, params Params) {
+			mw.NextFunc(ctx, params, next)
+		}
+	}
+	return func(ctx *fasthttp.RequestCtx, params Params) {
+		if ok := mw.MidFunc(ctx); !ok {
+			writeRouterStatus(ctx, mw.FailCode, "")
+			return
+		}
+		next(ctx, params)
+	}
+}
+
+// chain returns the handler wrapped by the middlewares,
+// so that the first middleware is the outermost one
+func chain(handler Handler, mws []MiddleWare) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i].wrap(handler)
+	}
+	return handler
+}
+
+// nextMiddleWares returns the onion style middlewares of the router
+func (r *Rox) nextMiddleWares() (mws []MiddleWare) {
+	for _, mw := range r.middlewares {
+		if mw.NextFunc != nil {
+			mws = append(mws, mw)
+		}
+	}
+	return
+}
@@ -0,0 +1,118 @@
+package rox
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// mount is a sub-router attached under a path prefix
+type mount struct {
+	prefix string
+	sub    *Rox
+	merged bool
+}
+
+// prefixHandler is a not-found handler scoped to a mounted subtree
+type prefixHandler struct {
+	prefix  string
+	handler fasthttp.RequestHandler
+}
+
+// Mount attaches an independently built router under prefix.
+// The sub-router's routes, middlewares and static file routes are merged
+// into this router by PrepareServer, so routes may still be added to sub after mounting.
+// Middlewares of the sub-router apply only to its own routes,
+// and its CustomNotFoundHandler, if any, applies to unknown paths under prefix
+// Example:
+//
+//	users := rox.New()
+//	users.Get("/:id", userHandler)
+//	r.Mount("/users", users) // -> /users/:id
+func (r *Rox) Mount(prefix string, sub *Rox) {
+	if sub == nil {
+		panic("router: nil sub-router")
+	}
+	if sub == r {
+		panic("router: cannot mount a router on itself")
+	}
+	prefix = cleanPrefix(prefix) // "/" mounts at the root, as ""
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		panic("router: mount prefix no leading / - " + prefix)
+	}
+	sub.parent = r
+	r.mounts = append(r.mounts, &mount{prefix: prefix, sub: sub})
+}
+
+// mergeMounts merges the routes of mounted sub-routers into our trees
+func (r *Rox) mergeMounts() {
+	for _, m := range r.mounts {
+		if m.merged {
+			continue
+		}
+		m.merged = true
+		m.sub.mergeMounts() // sub-routers may have mounts of their own
+
+		for _, method := range methods {
+			src, dst := m.sub.selectTree(method), r.selectTree(method)
+			for path, rt := range src.static {
+				p := MustPattern(r.newPattern(joinPath(m.prefix, path), &dst.Regs))
+				dst.Add(p, rt.h, rt.owner, m.sub.routeMiddleWares(rt)...)
+			}
+			for _, rt := range src.routes {
+				p := MustPattern(r.newPattern(joinPath(m.prefix, rt.p.pattern), &dst.Regs))
+				dst.Add(p, rt.h, rt.owner, m.sub.routeMiddleWares(&rt)...)
+			}
+		}
+
+		for _, ap := range m.sub.Options.assetPaths {
+			ap.handler, ap.hashed = nil, nil // built for the merged prefix
+			ap.Prefix = []byte(m.prefix + string(ap.Prefix))
+			exclude := make([]string, 0, len(ap.Opts.Exclude))
+			for _, prefix := range ap.Opts.Exclude {
+				exclude = append(exclude, m.prefix+prefix)
+			}
+			ap.Opts.Exclude = exclude
+			ap.StripSlashes += strings.Count(m.prefix, "/")
+			mws := make([]MiddleWare, 0, len(m.sub.middlewares)+len(ap.middlewares))
+			mws = append(mws, m.sub.middlewares...)
+			ap.middlewares = append(mws, ap.middlewares...)
+			r.Options.assetPaths = append(r.Options.assetPaths, ap)
+		}
+
+		if m.sub.Options.CustomNotFoundHandler != nil {
+			r.notFoundHandlers = append(r.notFoundHandlers,
+				prefixHandler{prefix: m.prefix, handler: *m.sub.Options.CustomNotFoundHandler})
+		}
+		for _, ph := range m.sub.notFoundHandlers {
+			r.notFoundHandlers = append(r.notFoundHandlers,
+				prefixHandler{prefix: m.prefix + ph.prefix, handler: ph.handler})
+		}
+	}
+}
+
+// routeMiddleWares returns all the middlewares of a route once it is mounted:
+// the sub-router's global middlewares followed by the route's own
+func (r *Rox) routeMiddleWares(rt *route) []MiddleWare {
+	mws := make([]MiddleWare, 0, len(r.middlewares)+len(rt.mws))
+	mws = append(mws, r.middlewares...)
+	return append(mws, rt.mws...)
+}
+
+// notFoundFor returns the not-found handler of the deepest mounted subtree containing path,
+// or the router's own not-found handler
+func (r *Rox) notFoundFor(path string) fasthttp.RequestHandler {
+	handler, longest := r.notFoundHandler, -1 // a sub-router mounted at the root has the prefix ""
+	for _, ph := range r.notFoundHandlers {
+		if len(ph.prefix) > longest && hasPathPrefix(path, ph.prefix) {
+			handler, longest = ph.handler, len(ph.prefix)
+		}
+	}
+	return handler
+}
+
+// hasPathPrefix reports whether path is prefix or lies below it
+func hasPathPrefix(path, prefix string) bool {
+	return strings.HasPrefix(path, prefix) &&
+		(len(path) == len(prefix) || path[len(prefix)] == '/')
+}
@@ -5,7 +5,11 @@ package rox
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
 	"sync"
+	"time"
 )
 
 const (
@@ -31,6 +35,86 @@ func (p Params) ByName(name string) string {
 	return ""
 }
 
+// lookup returns the value of the first parameter that matched the given name,
+// or an error if there is no such parameter.
+func (p Params) lookup(name string) (string, error) {
+	for i, v := range p.names {
+		if v == name {
+			return p.Value(i), nil
+		}
+	}
+	return "", fmt.Errorf("param %q not found", name)
+}
+
+// Int returns the value of the named parameter as an int.
+func (p Params) Int(name string) (int, error) {
+	v, err := p.lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("param %q is not an int - %w", name, err)
+	}
+	return i, nil
+}
+
+// Int64 returns the value of the named parameter as an int64.
+func (p Params) Int64(name string) (int64, error) {
+	v, err := p.lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("param %q is not an int64 - %w", name, err)
+	}
+	return i, nil
+}
+
+// Uint64 returns the value of the named parameter as an uint64.
+func (p Params) Uint64(name string) (uint64, error) {
+	v, err := p.lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("param %q is not an uint64 - %w", name, err)
+	}
+	return i, nil
+}
+
+// UUID returns the value of the named parameter as an UUID.
+func (p Params) UUID(name string) (UUID, error) {
+	v, err := p.lookup(name)
+	if err != nil {
+		return UUID{}, err
+	}
+	u, err := ParseUUID(v)
+	if err != nil {
+		return UUID{}, fmt.Errorf("param %q - %w", name, err)
+	}
+	return u, nil
+}
+
+// Time returns the value of the named parameter parsed with the given layout.
+// An empty layout is DateLayout, the format of the "date" parameter type.
+func (p Params) Time(name, layout string) (time.Time, error) {
+	v, err := p.lookup(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if layout == "" {
+		layout = DateLayout
+	}
+	t, err := time.Parse(layout, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("param %q is not a time - %w", name, err)
+	}
+	return t, nil
+}
+
 // Count returns the number of parameters.
 func (p Params) Count() int {
 	return len(p.names)
@@ -47,6 +131,54 @@ func (p Params) Value(i int) string {
 	return p.path[p.indices[i]:p.indices[i+1]]
 }
 
+// DateLayout is the time layout of the "date" parameter type.
+const DateLayout = "2006-01-02"
+
+// UUID is a RFC 4122 universally unique identifier.
+type UUID [16]byte
+
+// ParseUUID parses an UUID in the canonical form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx.
+func ParseUUID(s string) (u UUID, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	j := 0
+	for _, part := range [...]string{s[0:8], s[9:13], s[14:18], s[19:23], s[24:36]} {
+		n, err := hex.Decode(u[j:], []byte(part))
+		if err != nil {
+			return UUID{}, fmt.Errorf("invalid UUID %q", s)
+		}
+		j += n
+	}
+	return u, nil
+}
+
+// String returns the canonical form of the UUID.
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(text []byte) (err error) {
+	*u, err = ParseUUID(string(text))
+	return
+}
+
 // PathParams pulls the path parameters from a request context,
 // or returns nil if none are present.
 func PathParams(c context.Context) *Params {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 	"unsafe"
 )
 
@@ -26,8 +27,12 @@ type Pattern struct {
 // 	Segment		= LITERAL | Parameter
 //	Parameter	= Anonymous | Named
 //	Anonymous	= ":" | "*"
-//	Named		= ":" FieldPath [ "=" Regexp ] | "*" FieldPath
+//	Named		= ":" FieldPath [ "=" Regexp | "|" Type ] | "*" FieldPath
 // 	FieldPath	= IDENT { "." IDENT }
+//	Type		= "int" | "uint" | "uuid" | "date"
+//
+// A typed parameter only matches values of its type, eg "/orders/:id|int".
+// Types are shortcuts for regular expressions, see RegisterParamType.
 //
 func NewPattern(pattern string, regexps *[]*regexp.Regexp) (p Pattern, err error) {
 	var fields []string
@@ -60,7 +65,16 @@ func NewPattern(pattern string, regexps *[]*regexp.Regexp) (p Pattern, err error
 			}
 
 			reSep := strings.IndexByte(nameAndRe, '=') // Search for a name/regexp separator.
-			if reSep < 0 {                             // only name
+			typeSep := strings.IndexByte(nameAndRe, '|')
+			if typeSep >= 0 && (reSep < 0 || typeSep < reSep) { // typed parameter
+				var ok bool
+				reSep = typeSep
+				if nameAndRe, ok = typedParamRegexp(nameAndRe[:typeSep], nameAndRe[typeSep+1:]); !ok {
+					err = fmt.Errorf("pattern has unknown parameter type - %q", segments)
+					return
+				}
+			}
+			if reSep < 0 { // only name
 				fields = append(fields, nameAndRe)
 			} else {
 				fields = append(fields, nameAndRe[:reSep])
@@ -106,6 +120,51 @@ func NewPattern(pattern string, regexps *[]*regexp.Regexp) (p Pattern, err error
 	}, nil
 }
 
+// paramTypes maps the parameter types to the regular expressions their values must match.
+// The date expression checks the month lengths and leap years, so that a matched date always parses
+var paramTypes = map[string]string{
+	"int":  `^[-+]?[0-9]+$`,
+	"uint": `^[0-9]+$`,
+	"uuid": `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`,
+	"date": `^(?:[0-9]{4}-(?:(?:0[13578]|1[02])-(?:0[1-9]|[12][0-9]|3[01])|(?:0[469]|11)-(?:0[1-9]|[12][0-9]|30)|02-(?:0[1-9]|1[0-9]|2[0-8]))` +
+		`|(?:[0-9]{2}(?:0[48]|[2468][048]|[13579][26])|(?:[02468][048]|[13579][26])00)-02-29)$`,
+}
+
+var paramTypesMu sync.RWMutex
+
+// RegisterParamType adds a parameter type, or replaces one, whose values must match the regular expression.
+// Register types before the routes using them
+// Example:
+//
+//	rox.RegisterParamType("slug", `^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+//	r.Get("/posts/:slug|slug", postHandler)
+func RegisterParamType(typ, expr string) error {
+	if typ == "" || strings.ContainsAny(typ, "/=|") {
+		return fmt.Errorf("invalid parameter type name - %q", typ)
+	}
+	if expr == "" || strings.Contains(expr, "/") {
+		return fmt.Errorf("invalid regular expression for parameter type %q - %q", typ, expr)
+	}
+	if _, err := regexp.Compile(expr); err != nil {
+		return fmt.Errorf("invalid regular expression for parameter type %q - %w", typ, err)
+	}
+	paramTypesMu.Lock()
+	defer paramTypesMu.Unlock()
+	paramTypes[typ] = expr
+	return nil
+}
+
+// typedParamRegexp returns the "name=regexp" form of a typed parameter
+func typedParamRegexp(name, typ string) (nameAndRe string, ok bool) {
+	paramTypesMu.RLock()
+	expr, ok := paramTypes[typ]
+	paramTypesMu.RUnlock()
+	if !ok {
+		return
+	}
+	return name + "=" + expr, true
+}
+
 // MustPattern is a helper function which makes it easier to call NewPattern in variable initialization.
 func MustPattern(p Pattern, err error) Pattern {
 	if err != nil {
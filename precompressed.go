@@ -0,0 +1,116 @@
+package rox
+
+import (
+	"bytes"
+	"io/fs"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// precompressedEncoding is a content encoding and the file name extension of the files it encodes
+type precompressedEncoding struct {
+	name, ext string
+}
+
+// precompressedEncodings are the supported encodings of precompressed files, in order of preference
+var precompressedEncodings = []precompressedEncoding{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// precompressedServer serves the precompressed siblings of the files of a static route
+type precompressedServer struct {
+	files     *fsFileServer
+	urlPrefix string
+	// encodings maps the names of files with precompressed siblings to the available encodings
+	encodings map[string][]precompressedEncoding
+	next      fasthttp.RequestHandler
+}
+
+// newPrecompressedHandler finds the precompressed siblings of the files of the static route,
+// and returns a handler serving them, or else falling back to the next file handler
+func newPrecompressedHandler(ap *AssetPath, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	fsys, urlPrefix, err := ap.files()
+	if err != nil {
+		log.Println("Cannot find precompressed files of static route", string(ap.Prefix), "-", err)
+		return next
+	}
+
+	ps := &precompressedServer{
+		files:     &fsFileServer{fsys: fsys, opts: ap.Opts, modTime: time.Now()},
+		urlPrefix: urlPrefix,
+		encodings: make(map[string][]precompressedEncoding),
+		next:      next,
+	}
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		for _, enc := range precompressedEncodings {
+			if _, err := fs.Stat(fsys, name+enc.ext); err == nil {
+				ps.encodings[name] = append(ps.encodings[name], enc)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Cannot find precompressed files of static route", string(ap.Prefix), "-", err)
+		return next
+	}
+	return ps.serve
+}
+
+func (ps *precompressedServer) serve(ctx *fasthttp.RequestCtx) {
+	name := strings.TrimPrefix(string(ctx.Path()), ps.urlPrefix)
+	if enc, ok := ps.negotiate(ctx, name); ok {
+		if f, err := ps.files.fsys.Open(name + enc.ext); err == nil {
+			if fi, err := f.Stat(); err == nil {
+				ps.files.serveContent(ctx, f, fi, name, enc.name)
+				return
+			}
+			_ = f.Close()
+		}
+	}
+
+	ps.next(ctx)
+	switch ctx.Response.StatusCode() {
+	case fasthttp.StatusOK, fasthttp.StatusPartialContent, fasthttp.StatusNotModified:
+		ctx.Response.Header.Set("Vary", "Accept-Encoding")
+	}
+}
+
+// negotiate returns the preferred precompressed encoding of the file accepted by the client, if any
+func (ps *precompressedServer) negotiate(ctx *fasthttp.RequestCtx, name string) (precompressedEncoding, bool) {
+	if !ctx.IsGet() && !ctx.IsHead() {
+		return precompressedEncoding{}, false
+	}
+	acceptEncoding := ctx.Request.Header.Peek(fasthttp.HeaderAcceptEncoding)
+	for _, enc := range ps.encodings[name] {
+		if acceptsEncoding(acceptEncoding, enc.name) {
+			return enc, true
+		}
+	}
+	return precompressedEncoding{}, false
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header value accepts the encoding,
+// by name or by *, with a non zero quality
+func acceptsEncoding(acceptEncoding []byte, encoding string) bool {
+	accepted := false
+	for _, part := range bytes.Split(acceptEncoding, []byte(",")) {
+		coding, params, _ := strings.Cut(strings.TrimSpace(string(part)), ";")
+		coding = strings.TrimSpace(coding)
+		if !strings.EqualFold(coding, encoding) && coding != "*" {
+			continue
+		}
+		q := acceptQuality(params)
+		if strings.EqualFold(coding, encoding) { // an explicit coding overrides *
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
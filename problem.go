@@ -0,0 +1,165 @@
+package rox
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Problem is an RFC 7807 problem details document.
+// Problem is also an error, so ErrHandlers can return it to DefaultErrorHandler as is
+type Problem struct {
+	Type       string         // URI reference identifying the problem type, "about:blank" when empty
+	Title      string         // short summary of the problem type
+	Status     int            // HTTP status code
+	Detail     string         // explanation specific to this occurrence of the problem
+	Instance   string         // URI reference identifying this occurrence of the problem
+	Extensions map[string]any // extension members, written alongside the standard members
+}
+
+// NewProblem returns a Problem for the status code, titled with the status text
+func NewProblem(status int, detail string) Problem {
+	return Problem{Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+func (p Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + " - " + p.Detail
+}
+
+// MarshalJSON writes the standard members, omitting empty ones, and the extension members
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	for k, v := range map[string]string{"type": p.Type, "title": p.Title, "detail": p.Detail, "instance": p.Instance} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON reads the standard members, and any other member into Extensions
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*p = Problem{}
+	for k, v := range m {
+		s, _ := v.(string)
+		switch k {
+		case "type":
+			p.Type = s
+		case "title":
+			p.Title = s
+		case "detail":
+			p.Detail = s
+		case "instance":
+			p.Instance = s
+		case "status":
+			if f, ok := v.(float64); ok {
+				p.Status = int(f)
+			}
+		default:
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]any)
+			}
+			p.Extensions[k] = v
+		}
+	}
+	return nil
+}
+
+// WriteProblem writes the problem as an application/problem+json response
+func WriteProblem(ctx *fasthttp.RequestCtx, p Problem) {
+	if p.Status == 0 {
+		p.Status = fasthttp.StatusInternalServerError
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.SetStatusCode(p.Status)
+	ctx.SetContentType(ContentTypeProblemJson)
+	ctx.SetBody(body)
+}
+
+// AcceptsJSON reports whether the Accept header of the request asks for JSON,
+// eg application/json or application/problem+json, with a non zero quality
+func AcceptsJSON(ctx *fasthttp.RequestCtx) bool {
+	for _, part := range strings.Split(string(ctx.Request.Header.Peek(fasthttp.HeaderAccept)), ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if (strings.HasSuffix(mediaType, "/json") || strings.HasSuffix(mediaType, "+json")) && acceptQuality(params) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptQuality returns the q parameter among the parameters of an Accept header element, 1 if absent
+func acceptQuality(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		if k, v, ok := strings.Cut(param, "="); ok && strings.TrimSpace(k) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return q
+			}
+		}
+	}
+	return 1
+}
+
+// writeRouterStatus sets the status code of a response generated by the router itself.
+// Unless a handler or middleware already wrote a body or set the content type,
+// clients accepting JSON get a problem document, others the detail as plain text, if any
+func writeRouterStatus(ctx *fasthttp.RequestCtx, status int, detail string) {
+	ctx.SetStatusCode(status)
+	if ctx.Response.IsBodyStream() || len(ctx.Response.Body()) > 0 || contentTypeSet(&ctx.Response.Header) {
+		return
+	}
+	if AcceptsJSON(ctx) {
+		p := NewProblem(status, detail)
+		p.Instance = string(ctx.Path())
+		WriteProblem(ctx, p)
+		return
+	}
+	if detail != "" {
+		_, _ = ctx.WriteString(detail)
+	}
+}
+
+// defaultContentType is the Content-Type fasthttp reports for responses without one,
+// unless the server has NoDefaultContentType set
+var defaultContentType = new(fasthttp.ResponseHeader).ContentType()
+
+// contentTypeSet reports whether the Content-Type of the response was set, rather than being the default one.
+// fasthttp returns its shared default value for a response without a Content-Type, so this leaves the header untouched
+func contentTypeSet(h *fasthttp.ResponseHeader) bool {
+	ct := h.ContentType()
+	return len(ct) > 0 && &ct[0] != &defaultContentType[0]
+}
+
+// problemFrom returns the Problem in the error chain of err
+func problemFrom(err error) (p Problem, ok bool) {
+	var pp *Problem
+	switch {
+	case errors.As(err, &p):
+		return p, true
+	case errors.As(err, &pp):
+		return *pp, true
+	}
+	return
+}
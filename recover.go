@@ -0,0 +1,67 @@
+package rox
+
+import (
+	"log"
+	"runtime/debug"
+
+	"github.com/valyala/fasthttp"
+)
+
+// HeaderRequestID is the header carrying the request ID reported with panics
+const HeaderRequestID = "X-Request-Id"
+
+// PanicInfo describes a panic recovered while handling a request.
+// It holds copies of the request data, so unlike the pooled RequestCtx it may be retained
+type PanicInfo struct {
+	Value     any    // value passed to panic
+	Stack     []byte // stack trace of the panicking goroutine
+	Method    string
+	Path      string
+	Pattern   string // pattern of the matched route, empty if the panic happened before routing
+	RequestID string // X-Request-Id of the request, or else of the response
+}
+
+// PanicHandler writes the response for a recovered panic.
+// The response is reset and its status set to 500 before it is called
+type PanicHandler func(ctx *fasthttp.RequestCtx, info PanicInfo)
+
+// handlePanic reports a panic recovered from a handler or middleware and writes the response.
+// noDefaultContentType is the server setting, which resetting the response discards
+func (r *Rox) handlePanic(ctx *fasthttp.RequestCtx, rec any, pattern string, noDefaultContentType bool) {
+	info := PanicInfo{
+		Value:     rec,
+		Stack:     debug.Stack(),
+		Method:    string(ctx.Method()),
+		Path:      string(ctx.Path()),
+		Pattern:   pattern,
+		RequestID: string(ctx.Request.Header.Peek(HeaderRequestID)),
+	}
+	if info.RequestID == "" {
+		info.RequestID = string(ctx.Response.Header.Peek(HeaderRequestID))
+	}
+
+	// Drop whatever the handler wrote, but keep the request ID for the client
+	ctx.Response.Reset()
+	ctx.Response.Header.SetNoDefaultContentType(noDefaultContentType)
+	if info.RequestID != "" {
+		ctx.Response.Header.Set(HeaderRequestID, info.RequestID)
+	}
+	ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+
+	// The panic handler of the matched route's router, or of the routers it is mounted in
+	for rx := r.routeOwner(ctx); rx != nil; rx = rx.parent {
+		if rx.Options.CustomPanicHandler != nil {
+			rx.Options.CustomPanicHandler(ctx, info)
+			return
+		}
+	}
+	DefaultPanicHandler(ctx, info)
+}
+
+// DefaultPanicHandler logs the panic with its stack and answers 500,
+// as a problem document for clients accepting JSON
+func DefaultPanicHandler(ctx *fasthttp.RequestCtx, info PanicInfo) {
+	log.Printf("Panic: %v\nmethod: %s, path: %s, route: %s, request id: %s\n%s",
+		info.Value, info.Method, info.Path, info.Pattern, info.RequestID, info.Stack)
+	writeRouterStatus(ctx, fasthttp.StatusInternalServerError, "")
+}
@@ -0,0 +1,61 @@
+package rox
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+const acmeChallengePrefix = "/.well-known/acme-challenge/"
+
+// redirectHandler redirects requests to the HTTPS origin on Options.TLS.RedirectPort,
+// or else on the port of tlsAddr.
+// GET and HEAD get a 301, other methods a 308 so that the method and body are kept.
+// ACME HTTP-01 challenges are served from Options.TLS.ACMEChallengeRoot, if set
+func (r *Rox) redirectHandler(tlsAddr net.Addr) fasthttp.RequestHandler {
+	httpsPort := r.Options.TLS.RedirectPort
+	if tcpAddr, ok := tlsAddr.(*net.TCPAddr); ok && httpsPort == "" {
+		httpsPort = strconv.Itoa(tcpAddr.Port)
+	}
+	if httpsPort == "443" {
+		httpsPort = ""
+	}
+
+	var acmeHandler fasthttp.RequestHandler
+	if root := r.Options.TLS.ACMEChallengeRoot; root != "" {
+		acmeHandler = (&fasthttp.FS{Root: root}).NewRequestHandler()
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		if acmeHandler != nil && strings.HasPrefix(string(ctx.Path()), acmeChallengePrefix) &&
+			(ctx.IsGet() || ctx.IsHead()) {
+			acmeHandler(ctx)
+			return
+		}
+
+		host := string(ctx.Host())
+		if host == "" {
+			writeRouterStatus(ctx, fasthttp.StatusBadRequest, "Missing Host header")
+			return
+		}
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") { // IPv6 literal without port
+			host = host[1 : len(host)-1]
+		}
+		if httpsPort != "" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") { // IPv6 literal
+			host = "[" + host + "]"
+		}
+
+		status := fasthttp.StatusMovedPermanently
+		if !ctx.IsGet() && !ctx.IsHead() {
+			status = fasthttp.StatusPermanentRedirect
+		}
+		ctx.SetConnectionClose()
+		ctx.Redirect("https://"+host+string(ctx.RequestURI()), status)
+	}
+}
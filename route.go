@@ -12,8 +12,10 @@ import (
 // Api registers an api.
 // 	- method:  supported HTTP methods,
 // 	- pattern: url path matched pattern,
-// 	- handler: http request handler.
-func (r *Rox) Api(method string, pattern string, handler Handler) {
+// 	- handler: http request handler,
+// 	- mws:     optional middlewares run only for this route.
+// The returned reference can be used to name the route.
+func (r *Rox) Api(method string, pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
 	if handler == nil {
 		panic("router: nil handler")
 	}
@@ -27,47 +29,48 @@ func (r *Rox) Api(method string, pattern string, handler Handler) {
 		panic(fmt.Errorf("router: unknown http method - %q", method))
 	}
 	p := MustPattern(r.newPattern(pattern, &t.Regs))
-	t.Add(p, handler)
+	t.Add(p, handler, r, mws...)
+	return &RouteRef{rox: r, pattern: pattern}
 }
 
 // Get is a shortcut for Api(http.MethodGet, pattern, handler)
-func (r *Rox) Get(pattern string, handler Handler) {
-	r.Api(http.MethodGet, pattern, handler)
+func (r *Rox) Get(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return r.Api(http.MethodGet, pattern, handler, mws...)
 }
 
 // Post is a shortcut for Api(http.MethodPost, pattern, handler)
-func (r *Rox) Post(pattern string, handler Handler) {
-	r.Api(http.MethodPost, pattern, handler)
+func (r *Rox) Post(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return r.Api(http.MethodPost, pattern, handler, mws...)
 }
 
 // GetPost set Get and Post methods for pattern, handler)
-func (r *Rox) GetPost(pattern string, handler Handler) {
-	r.Api(http.MethodGet, pattern, handler)
-	r.Api(http.MethodPost, pattern, handler)
+func (r *Rox) GetPost(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	r.Api(http.MethodGet, pattern, handler, mws...)
+	return r.Api(http.MethodPost, pattern, handler, mws...)
 }
 
 // Put is a shortcut for Api(http.MethodPut, pattern, handler)
-func (r *Rox) Put(pattern string, handler Handler) {
-	r.Api(http.MethodPut, pattern, handler)
+func (r *Rox) Put(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return r.Api(http.MethodPut, pattern, handler, mws...)
 }
 
 // Delete is a shortcut for Api(http.MethodDelete, pattern, handler)
-func (r *Rox) Delete(pattern string, handler Handler) {
-	r.Api(http.MethodDelete, pattern, handler)
+func (r *Rox) Delete(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return r.Api(http.MethodDelete, pattern, handler, mws...)
 }
 
 // Head is a shortcut for Api(http.MethodHead, pattern, handler)
-func (r *Rox) Head(pattern string, handler Handler) {
-	r.Api(http.MethodHead, pattern, handler)
+func (r *Rox) Head(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return r.Api(http.MethodHead, pattern, handler, mws...)
 }
 
 // MethodOptions is a shortcut for Api(http.MethodOptions, pattern, handler)
 // Sorry for the asymmetry, but we will use Options for actual router options
-func (r *Rox) MethodOptions(pattern string, handler Handler) {
-	r.Api(http.MethodOptions, pattern, handler)
+func (r *Rox) MethodOptions(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return r.Api(http.MethodOptions, pattern, handler, mws...)
 }
 
 // Patch is a shortcut for Api(http.MethodPatch, pattern, handler)
-func (r *Rox) Patch(pattern string, handler Handler) {
-	r.Api(http.MethodPatch, pattern, handler)
+func (r *Rox) Patch(pattern string, handler Handler, mws ...MiddleWare) *RouteRef {
+	return r.Api(http.MethodPatch, pattern, handler, mws...)
 }
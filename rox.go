@@ -1,10 +1,11 @@
 package rox
 
 import (
-	"bytes"
 	"fmt"
 	"log"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
@@ -28,6 +29,19 @@ type Rox struct {
 	options         tree
 	newPattern      func(string, *[]*regexp.Regexp) (Pattern, error)
 	notFoundHandler fasthttp.RequestHandler
+	// methodNotAllowedHandler is called when the path matches under other methods only
+	methodNotAllowedHandler fasthttp.RequestHandler
+	// names maps route names to their url templates
+	names map[string]*urlTemplate
+	// parent is the router this router is mounted in
+	parent *Rox
+	// mounted sub-routers and their not-found handlers
+	mounts           []*mount
+	notFoundHandlers []prefixHandler
+	// assets maps the url paths of fingerprinted static files to their content-hashed url paths
+	assets map[string]string
+	// lifecycle of the server
+	lifecycle
 }
 
 type Options struct {
@@ -37,6 +51,51 @@ type Options struct {
 	assetPaths            []AssetPath
 	CustomMasterHandler   *fasthttp.RequestHandler
 	CustomNotFoundHandler *fasthttp.RequestHandler
+	// Addr is the host:port to listen on, eg "127.0.0.1:8800", "[::1]:443" or "127.0.0.1:0".
+	// It takes precedence over Port
+	Addr string
+	// CustomMethodNotAllowedHandler is called when the path is registered for other methods only.
+	// The Allow header is already set, and the status is always 405
+	CustomMethodNotAllowedHandler *fasthttp.RequestHandler
+	// CustomErrorHandler writes the response for errors returned by ErrHandlers.
+	// When not set, the error handler of the router we are mounted in, or else DefaultErrorHandler is used
+	CustomErrorHandler ErrorHandler
+	// CustomPanicHandler writes the response for panics recovered from handlers and middlewares.
+	// Panics in routes of a mounted router go to its own handler, if set, before its parents'.
+	// When not set, DefaultPanicHandler is used
+	CustomPanicHandler PanicHandler
+	// AutoMethodOptions answers OPTIONS requests for registered paths having no explicit OPTIONS route
+	// with 204 and an Allow header listing the methods registered for the path
+	AutoMethodOptions bool
+	// AutoHead serves HEAD requests having no explicit HEAD route with the GET route, without the body
+	AutoHead bool
+	// DrainPeriod is how long the server keeps serving while failing readiness checks
+	// once shutdown has started, so that load balancers stop sending new requests
+	DrainPeriod time.Duration
+	// ShutdownTimeout bounds the wait for in-flight requests when Serve or ServeContext stop.
+	// Zero means defaultShutdownTimeout
+	ShutdownTimeout time.Duration
+	// Server configures the fasthttp.Server built by Serve
+	Server ServerOpts
+	// ServerCustomizer, if set, is called with the fasthttp.Server built by Serve
+	// after Server options are applied, for settings not covered by ServerOpts
+	ServerCustomizer func(s *fasthttp.Server)
+}
+
+// ServerOpts configures the fasthttp.Server. Zero values keep the fasthttp defaults
+type ServerOpts struct {
+	Name               string        // Server header value
+	ReadTimeout        time.Duration // max duration for reading a full request, including the body
+	WriteTimeout       time.Duration // max duration for writing a full response
+	IdleTimeout        time.Duration // max wait for the next request on a keep-alive connection
+	MaxRequestBodySize int           // in bytes, larger requests get a 413
+	Concurrency        int           // max number of concurrent connections
+	ReadBufferSize     int           // per connection, also limits the header size
+	WriteBufferSize    int           // per connection
+	ReduceMemoryUsage  bool          // trade CPU for lower memory usage
+	StreamRequestBody  bool          // let handlers stream bodies over MaxRequestBodySize
+	DisableKeepalive   bool          // close connections after each response
+	Logger             fasthttp.Logger
 }
 
 type TLSOpts struct {
@@ -45,6 +104,28 @@ type TLSOpts struct {
 	KeyFile  string
 	CertData []byte
 	KeyData  []byte
+	// HTTPAddr, if set, also serves the routes over plain HTTP on this address, eg ":80"
+	HTTPAddr string
+	// RedirectAddr, if set, listens on this address, eg ":80", and redirects every request to HTTPS
+	RedirectAddr string
+	// RedirectPort is the public HTTPS port redirected to, eg "443" when the TLS listener sits behind a port forward.
+	// When empty, the port of the TLS listener is used
+	RedirectPort string
+	// ACMEChallengeRoot, if set, is the webroot from which the redirect listener serves
+	// /.well-known/acme-challenge/ (eg as used by certbot --webroot)
+	ACMEChallengeRoot string
+	// Certs are additional certificates. Each handshake gets the certificate matching its SNI host name,
+	// else the certificate above, else the first of Certs
+	Certs []Cert
+	// ReloadInterval, if set, is how often the certificate files are checked for changes and reloaded
+	ReloadInterval time.Duration
+	// ReloadOnSIGHUP reloads the certificate files when the process receives SIGHUP
+	ReloadOnSIGHUP bool
+	// ClientAuth is the mutual TLS policy for client certificates, see ClientIdentity
+	ClientAuth ClientAuthMode
+	// ClientCAFile or ClientCAData is the PEM bundle of the CAs that client certificates are verified against
+	ClientCAFile string
+	ClientCAData []byte
 }
 
 // New returns a new Rox which is initialized with
@@ -62,32 +143,15 @@ func New(opts ...Options) *Rox {
 	return r
 }
 
-// Serve dispatches the request to the first handler
-// which matches to req.Method and req.Path.
-func (r *Rox) Serve() {
-	mainReqHandler := r.PrepareServer()
-
-	if r.Options.Verbose {
-		fmt.Println("Rox listening on port:", r.Options.Port)
-	}
-
-	if r.Options.TLS.UseTLS && r.Options.TLS.CertFile != "" {
-		log.Fatal(fasthttp.ListenAndServeTLS(ipAny+":"+r.Options.Port, r.Options.TLS.CertFile,
-			r.Options.TLS.KeyFile, mainReqHandler))
-	} else if r.Options.TLS.UseTLS && len(r.Options.TLS.CertData) > 0 {
-		log.Fatal(fasthttp.ListenAndServeTLSEmbed(ipAny+":"+r.Options.Port, r.Options.TLS.CertData,
-			r.Options.TLS.KeyData, mainReqHandler))
-	} else {
-		log.Fatal(fasthttp.ListenAndServe(":"+r.Options.Port, mainReqHandler))
-	}
-}
-
 // PrepareServer prepares the routes and main handlers both for normal and test modes
 func (r *Rox) PrepareServer() fasthttp.RequestHandler {
 	if r.Options.Verbose {
 		fmt.Println("Preparing routes...")
 	}
+	r.mergeMounts()
 	r.initTrees()
+	r.wrapRoutes()
+	r.initAssetHandlers()
 
 	if r.Options.Port == "" {
 		if r.Options.TLS.UseTLS {
@@ -103,11 +167,20 @@ func (r *Rox) PrepareServer() fasthttp.RequestHandler {
 		notFoundHandler = *r.Options.CustomNotFoundHandler
 	} else {
 		notFoundHandler = func(c *fasthttp.RequestCtx) {
-			c.SetStatusCode(fasthttp.StatusNotFound)
+			writeRouterStatus(c, fasthttp.StatusNotFound, "")
 		}
 	}
 	r.notFoundHandler = notFoundHandler
 
+	// Get MethodNotAllowed handler
+	if r.Options.CustomMethodNotAllowedHandler != nil {
+		r.methodNotAllowedHandler = *r.Options.CustomMethodNotAllowedHandler
+	} else {
+		r.methodNotAllowedHandler = func(c *fasthttp.RequestCtx) {
+			writeRouterStatus(c, fasthttp.StatusMethodNotAllowed, "")
+		}
+	}
+
 	// Get Master handler
 	var mainReqHandler fasthttp.RequestHandler
 	if r.Options.CustomMasterHandler != nil {
@@ -120,10 +193,26 @@ func (r *Rox) PrepareServer() fasthttp.RequestHandler {
 
 func initStdMasterHandler(r *Rox) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
+		var pattern string // pattern of the matched route, for panic reports
+		// A response starts without a Content-Type when the server has NoDefaultContentType set
+		noDefaultContentType := len(ctx.Response.Header.ContentType()) == 0
+		defer func() {
+			if rec := recover(); rec != nil {
+				r.handlePanic(ctx, rec, pattern, noDefaultContentType)
+			}
+		}()
+
+		if r.Draining() { // move keep-alive clients off this server
+			ctx.SetConnectionClose()
+		}
+
 		// Middlewares - they modify ctx or fail with the provided code
 		for _, mw := range r.middlewares {
+			if mw.NextFunc != nil { // onion style middlewares are chained around the route handlers
+				continue
+			}
 			if ok := mw.MidFunc(ctx); !ok {
-				ctx.SetStatusCode(mw.FailCode)
+				writeRouterStatus(ctx, mw.FailCode, "")
 				return
 			}
 		}
@@ -135,35 +224,51 @@ func initStdMasterHandler(r *Rox) fasthttp.RequestHandler {
 			return
 		}
 
-		t := r.selectTree(string(ctx.Method()))
+		var params Params
+		var h Handler
+		path := string(ctx.Path())
+		method := string(ctx.Method())
+		t := r.selectTree(method)
 		if t != nil {
-			var params Params
-			path := string(ctx.Path())
+			h, pattern = r.matchRoute(t, path, &params)
+		}
+		// HEAD falls back to GET. Fasthttp never sends the body of a HEAD response
+		if h == nil && r.Options.AutoHead && method == fasthttp.MethodHead {
+			h, pattern = r.matchRoute(&r.get, path, &params)
+		}
+		if h != nil {
+			h(ctx, params)
+			return
+		}
 
-			if h := t.StaticMatch(path); h != nil {
-				fmt.Println("Direct match:", path)
-				h(ctx, params)
-				return
-			}
+		// The path may be registered for other methods
+		allowed := r.allowedMethods(path)
+		if r.Options.AutoMethodOptions && method == fasthttp.MethodOptions && len(allowed) > 0 {
+			ctx.Response.Header.Set(HeaderAllow, strings.Join(allowed, ", "))
+			ctx.SetStatusCode(fasthttp.StatusNoContent)
+			return
+		}
 
-			h, patt := t.PatternMatch(path, &params)
-			if r.Options.Verbose && h != nil && patt != "" {
-				fmt.Println("Pattern match:", path, "->", patt)
-				h(ctx, params)
-				return
-			}
+		if len(allowed) > 0 {
+			log.Println("Method Not Allowed (405)", method, path)
+			ctx.Response.Header.Set(HeaderAllow, strings.Join(allowed, ", "))
+			r.methodNotAllowedHandler(ctx)
+
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+			return
+		}
 
+		if t != nil {
 			msg := "Unknown Route (404) " + path
 			log.Println(msg)
-			r.notFoundHandler(ctx)
+			r.notFoundFor(path)(ctx)
 
 			ctx.SetStatusCode(fasthttp.StatusNotFound)
 
 		} else {
 			const msg = "Unknown HTTP method"
 			log.Println(msg)
-			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
-			_, _ = ctx.WriteString(msg)
+			writeRouterStatus(ctx, fasthttp.StatusMethodNotAllowed, msg)
 		}
 	}
 }
@@ -180,6 +285,67 @@ func (r *Rox) initTrees() {
 	r.options.Init()
 }
 
+// wrapRoutes chains the onion style middlewares around the handlers of all routes
+func (r *Rox) wrapRoutes() {
+	mws := r.nextMiddleWares()
+	for _, method := range methods {
+		r.selectTree(method).Wrap(mws)
+	}
+}
+
+// matchRoute returns the handler and pattern of the route in t matching path, if any
+func (r *Rox) matchRoute(t *tree, path string, params *Params) (h Handler, pattern string) {
+	if h = t.StaticMatch(path); h != nil {
+		if r.Options.Verbose {
+			fmt.Println("Direct match:", path)
+		}
+		return h, path
+	}
+
+	if h, pattern = t.PatternMatch(path, params); h != nil {
+		if r.Options.Verbose {
+			fmt.Println("Pattern match:", path, "->", pattern)
+		}
+	}
+	return
+}
+
+// allowedMethods returns the methods having a route which matches path,
+// including those answered automatically by the router
+func (r *Rox) allowedMethods(path string) (allowed []string) {
+	var params Params
+	hasGet := false
+	for _, method := range methods {
+		h, _ := r.selectTree(method).match(path, &params)
+		ok := h != nil
+		switch method {
+		case fasthttp.MethodGet:
+			hasGet = ok
+		case fasthttp.MethodHead:
+			ok = ok || (hasGet && r.Options.AutoHead)
+		case fasthttp.MethodOptions: // always last
+			ok = ok || (len(allowed) > 0 && r.Options.AutoMethodOptions)
+		}
+		if ok {
+			allowed = append(allowed, method)
+		}
+	}
+	return
+}
+
+// methods lists the HTTP methods which have a route tree
+var methods = []string{
+	fasthttp.MethodGet,
+	fasthttp.MethodPost,
+	fasthttp.MethodDelete,
+	fasthttp.MethodPut,
+	fasthttp.MethodPatch,
+	fasthttp.MethodHead,
+	fasthttp.MethodConnect,
+	fasthttp.MethodTrace,
+	fasthttp.MethodOptions,
+}
+
 // selectTree returns the tree by the given HTTP method.
 func (r *Rox) selectTree(method string) *tree {
 	switch method {
@@ -205,31 +371,3 @@ func (r *Rox) selectTree(method string) *tree {
 		return nil
 	}
 }
-
-type AssetPath struct {
-	Prefix         []byte // url prefix
-	FileSystemRoot string // file locations
-	StripSlashes   int    // how many slash words to strip from the url prefix
-}
-
-// Add a route to static files
-// Prefix is a starting portion of the URL delimited by slashes
-// fsRoot is the path to the top-level folder to serve files from
-// StripSlashes is the number of slash delimited tokens to remove from the URL
-// before appending it to the fsRoot to form the full file path
-// Example: rx.AddStaticFilesRoute("/images/", "artifacts/images", 1)
-func (r *Rox) AddStaticFilesRoute(prefix, fsRoot string, slashesToStrip int) {
-	ap := AssetPath{Prefix: []byte(prefix), FileSystemRoot: fsRoot, StripSlashes: slashesToStrip}
-	r.Options.assetPaths = append(r.Options.assetPaths, ap)
-}
-
-// See if we match a file handler - First match is the one we use
-func (r *Rox) getFSHandler(ctx *fasthttp.RequestCtx) (handler fasthttp.RequestHandler, ok bool) {
-	path := ctx.Path()
-	for _, astPath := range r.Options.assetPaths {
-		if bytes.HasPrefix(path, astPath.Prefix) {
-			return fasthttp.FSHandler(astPath.FileSystemRoot, astPath.StripSlashes), true
-		}
-	}
-	return
-}
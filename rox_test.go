@@ -1,11 +1,22 @@
 package rox
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
@@ -36,6 +47,10 @@ func TestRox(t *testing.T) {
 			args: args{"GET", "/", nil},
 			resp: expectedResp{200, 400, "Hello there! Rox here."},
 		},
+		{name: "Onion middleware after handler",
+			args: args{"GET", "/", nil},
+			resp: expectedResp{200, 400, "X-Handled-Status: 200"},
+		},
 		{name: "Route with param",
 			args: args{"GET", "/greet/sue", nil},
 			resp: expectedResp{200, 400, "Hey sue"},
@@ -52,6 +67,62 @@ func TestRox(t *testing.T) {
 			args: args{"GET", "/student/john/class/Math", nil},
 			resp: expectedResp{200, 400, "john takes Math"},
 		},
+		{name: "Route with regexp and wildcard params",
+			args: args{"GET", "/year/2023/jan/01", nil},
+			resp: expectedResp{200, 400, "Year 2023 jan/01"},
+		},
+		{name: "Typed int param",
+			args: args{"GET", "/orders/42", nil},
+			resp: expectedResp{200, 400, "Order #43"},
+		},
+		{name: "Typed int param mismatch falls back",
+			args: args{"GET", "/orders/latest", nil},
+			resp: expectedResp{200, 400, "Order named latest"},
+		},
+		{name: "Typed uuid param",
+			args: args{"GET", "/accounts/6ba7b810-9dad-11d1-80b4-00c04fd430c8", nil},
+			resp: expectedResp{200, 400, "Account 6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		},
+		{name: "Typed uuid param mismatch",
+			args: args{"GET", "/accounts/6ba7b810", nil},
+			resp: expectedResp{404, 405, "404 Not Found"},
+		},
+		{name: "Typed date param",
+			args: args{"GET", "/days/2024-02-29", nil},
+			resp: expectedResp{200, 400, "Day Thursday"},
+		},
+		{name: "Typed date param leap century",
+			args: args{"GET", "/days/2000-02-29", nil},
+			resp: expectedResp{200, 400, "Day Tuesday"},
+		},
+		{name: "Typed date param invalid month and day",
+			args: args{"GET", "/days/2023-13-45", nil},
+			resp: expectedResp{404, 405, "Yo! It's not found"},
+		},
+		{name: "Typed date param not a leap year",
+			args: args{"GET", "/days/2023-02-29", nil},
+			resp: expectedResp{404, 405, "Yo! It's not found"},
+		},
+		{name: "Error handler HTTPError",
+			args: args{"GET", "/fail/teapot", nil},
+			resp: expectedResp{418, 419, `{"error":"short and stout"}`},
+		},
+		{name: "Error handler wrapped sentinel",
+			args: args{"GET", "/fail/missing", nil},
+			resp: expectedResp{404, 405, `{"error":"not found"}`},
+		},
+		{name: "Error handler validation error",
+			args: args{"GET", "/fail/invalid", nil},
+			resp: expectedResp{422, 423, `{"errors":[{"field":"name","message":"is required"}]}`},
+		},
+		{name: "Error handler other error",
+			args: args{"GET", "/fail/boom", nil},
+			resp: expectedResp{500, 501, `{"error":"Internal Server Error"}`},
+		},
+		{name: "Mounted custom error handler",
+			args: args{"GET", "/shop/fail", nil},
+			resp: expectedResp{500, 501, "Shop failed: out of stock"},
+		},
 		{name: "Static route CSS",
 			args: args{"GET", "/css/sample.css", nil},
 			resp: expectedResp{200, 400, "background-color"},
@@ -60,6 +131,42 @@ func TestRox(t *testing.T) {
 			args: args{"GET", "/images/dove.jpg", nil},
 			resp: expectedResp{200, 400, ""},
 		},
+		{name: "Group route",
+			args: args{"GET", "/api/v1/users/42", nil},
+			resp: expectedResp{200, 400, "User 42"},
+		},
+		{name: "Group root route",
+			args: args{"GET", "/api/v1", nil},
+			resp: expectedResp{200, 400, "API v1"},
+		},
+		{name: "Group middleware failure",
+			args: args{"GET", "/admin/dashboard", nil},
+			resp: expectedResp{401, 402, "401 Unauthorized"},
+		},
+		{name: "Route middleware failure",
+			args: args{"GET", "/reports/private", nil},
+			resp: expectedResp{403, 404, "403 Forbidden"},
+		},
+		{name: "Route middleware chain",
+			args: args{"GET", "/reports/public", nil},
+			resp: expectedResp{200, 400, "X-Report: public"},
+		},
+		{name: "Mounted route",
+			args: args{"GET", "/shop/items/7", nil},
+			resp: expectedResp{200, 400, "Item 7"},
+		},
+		{name: "Mounted root route",
+			args: args{"GET", "/shop", nil},
+			resp: expectedResp{200, 400, "Shop home"},
+		},
+		{name: "Mounted static route",
+			args: args{"GET", "/shop/css/sample.css", nil},
+			resp: expectedResp{200, 400, "X-Shop: yes"},
+		},
+		{name: "Mounted unknown route",
+			args: args{"GET", "/shop/nothing/here", nil},
+			resp: expectedResp{404, 405, "Shop item not found"},
+		},
 		{name: "Unknown route",
 			args: args{"GET", "/abcd/efg", nil},
 			resp: expectedResp{200, 400, "Yo! It's not found"},
@@ -85,11 +192,435 @@ func TestRox(t *testing.T) {
 	}
 }
 
+func TestMethodHandling(t *testing.T) {
+	plain := New()
+	plain.Get("/items", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Items")
+	})
+	plain.Post("/items", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Item added")
+	})
+	auto := initTestRox() // AutoMethodOptions, AutoHead and a custom 405 handler
+
+	tests := []struct {
+		name       string
+		r          *Rox
+		method     string
+		target     string
+		wantStatus int
+		wantAllow  string
+		wantBody   string
+		wantLength int // Content-Length, checked if set
+	}{
+		{name: "Allowed method", r: plain, method: "GET", target: "/items",
+			wantStatus: 200, wantBody: "Items"},
+		{name: "Method not allowed", r: plain, method: "DELETE", target: "/items",
+			wantStatus: 405, wantAllow: "GET, POST"},
+		{name: "Unknown path is not found, not 405", r: plain, method: "DELETE", target: "/nothing",
+			wantStatus: 404},
+		{name: "No automatic OPTIONS", r: plain, method: "OPTIONS", target: "/items",
+			wantStatus: 405, wantAllow: "GET, POST"},
+		{name: "Method not allowed on a pattern route", r: auto, method: "POST", target: "/greet/sue",
+			wantStatus: 405, wantAllow: "GET, HEAD, OPTIONS", wantBody: "Try another method"},
+		{name: "Method not allowed custom handler", r: auto, method: "PUT", target: "/",
+			wantStatus: 405, wantAllow: "GET, HEAD, OPTIONS", wantBody: "Try another method"},
+		{name: "Automatic OPTIONS", r: auto, method: "OPTIONS", target: "/items",
+			wantStatus: 204, wantAllow: "GET, POST, HEAD, OPTIONS"},
+		{name: "Automatic HEAD", r: auto, method: "HEAD", target: "/greet/sue",
+			wantStatus: 200, wantLength: len("Hey sue!")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := readResponse(t, tt.r, nil, httptest.NewRequest(tt.method, tt.target, nil))
+			if resp.StatusCode() != tt.wantStatus {
+				t.Errorf("status %d, want %d", resp.StatusCode(), tt.wantStatus)
+			}
+			if allow := string(resp.Header.Peek(HeaderAllow)); allow != tt.wantAllow {
+				t.Errorf("Allow %q, want %q", allow, tt.wantAllow)
+			}
+			if tt.wantBody != "" && string(resp.Body()) != tt.wantBody {
+				t.Errorf("body %q, want %q", resp.Body(), tt.wantBody)
+			}
+			if tt.wantLength != 0 && resp.Header.ContentLength() != tt.wantLength {
+				t.Errorf("Content-Length %d, want %d", resp.Header.ContentLength(), tt.wantLength)
+			}
+		})
+	}
+}
+
+// The date parameter type matches exactly the dates that parse
+func TestDateParamType(t *testing.T) {
+	re := regexp.MustCompile(paramTypes["date"])
+	for year := 1896; year <= 2104; year++ {
+		for month := 0; month <= 13; month++ {
+			for day := 0; day <= 32; day++ {
+				s := fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+				_, err := time.Parse(DateLayout, s)
+				if matched := re.MatchString(s); matched != (err == nil) {
+					t.Fatalf("date type matches %s: %v, but parsing error is %v", s, matched, err)
+				}
+			}
+		}
+	}
+}
+
+func TestRegisterParamType(t *testing.T) {
+	if err := RegisterParamType("slug", `^[a-z0-9]+(?:-[a-z0-9]+)*$`); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range [][2]string{{"", "^a$"}, {"a|b", "^a$"}, {"bad", "("}, {"path", "^a/b$"}} {
+		if err := RegisterParamType(bad[0], bad[1]); err == nil {
+			t.Errorf("RegisterParamType(%q, %q) should fail", bad[0], bad[1])
+		}
+	}
+
+	r := New()
+	r.Get("/posts/:slug|slug", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Post " + params.ByName("slug"))
+	})
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+	if resp := readResponse(t, r, fsvr, httptest.NewRequest("GET", "/posts/hello-world", nil)); resp.StatusCode() != 200 {
+		t.Errorf("GET /posts/hello-world: status %d", resp.StatusCode())
+	}
+	if resp := readResponse(t, r, fsvr, httptest.NewRequest("GET", "/posts/Hello_World", nil)); resp.StatusCode() != 404 {
+		t.Errorf("GET /posts/Hello_World: status %d", resp.StatusCode())
+	}
+}
+
+func TestURL(t *testing.T) {
+	r := initTestRox()
+
+	tests := []struct {
+		name    string
+		route   string
+		params  []string
+		want    string
+		wantErr bool
+	}{
+		{name: "Params", route: "class", params: []string{"name", "john", "className", "Math"},
+			want: "/student/john/class/Math"},
+		{name: "Escaped params", route: "class", params: []string{"className", "Art & Craft", "name", "jo/e"},
+			want: "/student/jo%2Fe/class/Art%20&%20Craft"},
+		{name: "Regexp and wildcard params", route: "archive", params: []string{"year", "2023", "path", "jan/01 a"},
+			want: "/year/2023/jan/01%20a"},
+		{name: "Typed param", route: "day", params: []string{"day", "2024-02-29"}, want: "/days/2024-02-29"},
+		{name: "Typed param mismatch", route: "day", params: []string{"day", "yesterday"}, wantErr: true},
+		{name: "Mounted route", route: "item", params: []string{"id", "7"}, want: "/shop/items/7"},
+		{name: "Regexp mismatch", route: "archive", params: []string{"year", "23", "path", "jan"}, wantErr: true},
+		{name: "Missing param", route: "class", params: []string{"name", "john"}, wantErr: true},
+		{name: "Odd params", route: "class", params: []string{"name"}, wantErr: true},
+		{name: "Unknown route", route: "nope", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.URL(tt.route, tt.params...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("URL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("URL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMountedAsset(t *testing.T) {
+	css, err := os.ReadFile("dist_test/css/sample.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(css)
+	hashed := "/shop/css/sample." + hex.EncodeToString(sum[:])[:8] + ".css"
+
+	r := New()
+	shop := New()
+	shop.AddStaticFilesRoute("/css/", "dist_test/css", 1, StaticOpts{Fingerprint: true})
+	r.Mount("/shop", shop)
+	r.PrepareServer()
+
+	for _, tt := range []struct {
+		name string
+		rx   *Rox
+		path string
+		want string
+	}{
+		{"Parent", r, "/shop/css/sample.css", hashed},
+		{"Mounted router", shop, "/css/sample.css", hashed},
+		{"Mounted router unknown file", shop, "/css/unknown.css", "/css/unknown.css"},
+	} {
+		if got := tt.rx.Asset(tt.path); got != tt.want {
+			t.Errorf("%s: Asset(%q) = %q, want %q", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultErrorHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{"HTTPError", HTTPError{Code: fasthttp.StatusTeapot, Msg: "short and stout"}, 418, `{"error":"short and stout"}`},
+		{"HTTPError pointer", &HTTPError{Code: fasthttp.StatusConflict}, 409, `{"error":"Conflict"}`},
+		{"HTTPError without code", HTTPError{Msg: "no code"}, 500, `{"error":"no code"}`},
+		{"Wrapped sentinel", fmt.Errorf("user 7: %w", ErrNotFound), 404, `{"error":"not found"}`},
+		{"Other error", errors.New("boom"), 500, `{"error":"Internal Server Error"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctx fasthttp.RequestCtx
+			DefaultErrorHandler(&ctx, tt.err)
+			if ctx.Response.StatusCode() != tt.wantStatus || string(ctx.Response.Body()) != tt.wantBody {
+				t.Errorf("status %d, body %s, want %d %s", ctx.Response.StatusCode(), ctx.Response.Body(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestProblem(t *testing.T) {
+	r := New()
+	r.Use(func(ctx *fasthttp.RequestCtx) (ok bool) {
+		return len(ctx.Request.Header.Peek("X-Blocked")) == 0
+	}, fasthttp.StatusForbidden)
+	r.Use(func(ctx *fasthttp.RequestCtx) (ok bool) {
+		if len(ctx.Request.Header.Peek("X-Expired")) > 0 {
+			_, _ = ctx.WriteString("Token expired")
+			return false
+		}
+		return true
+	}, fasthttp.StatusUnauthorized)
+	r.Get("/items", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Items")
+	})
+	r.ApiErr(fasthttp.MethodGet, "/quota", func(ctx *fasthttp.RequestCtx, params Params) error {
+		p := NewProblem(fasthttp.StatusTooManyRequests, "Daily quota exceeded")
+		p.Type = "https://example.com/probs/quota"
+		p.Extensions = map[string]any{"limit": 100}
+		return p
+	})
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+
+	tests := []struct {
+		name, method, target string
+		headers              map[string]string
+		want                 Problem
+		plain                bool   // no problem document expected
+		wantBody             string // body of a plain response
+	}{
+		{name: "Not found", method: "GET", target: "/nothing",
+			headers: map[string]string{"Accept": "application/json"},
+			want:    Problem{Title: "Not Found", Status: 404, Instance: "/nothing"}},
+		{name: "Method not allowed", method: "POST", target: "/items",
+			headers: map[string]string{"Accept": "application/problem+json"},
+			want:    Problem{Title: "Method Not Allowed", Status: 405, Instance: "/items"}},
+		{name: "Middleware failure", method: "GET", target: "/items",
+			headers: map[string]string{"Accept": "application/json", "X-Blocked": "1"},
+			want:    Problem{Title: "Forbidden", Status: 403, Instance: "/items"}},
+		{name: "Unknown method", method: "BREW", target: "/nothing",
+			headers: map[string]string{"Accept": "application/json"},
+			want:    Problem{Title: "Method Not Allowed", Status: 405, Detail: "Unknown HTTP method", Instance: "/nothing"}},
+		{name: "Returned problem", method: "GET", target: "/quota",
+			want: Problem{Type: "https://example.com/probs/quota", Title: "Too Many Requests", Status: 429,
+				Detail: "Daily quota exceeded", Extensions: map[string]any{"limit": float64(100)}}},
+		{name: "Not found without JSON", method: "GET", target: "/nothing",
+			headers: map[string]string{"Accept": "text/html"}, plain: true},
+		{name: "Not found with JSON refused", method: "GET", target: "/nothing",
+			headers: map[string]string{"Accept": "application/json;q=0, text/html"}, plain: true},
+		{name: "Middleware failure with a body", method: "GET", target: "/items",
+			headers: map[string]string{"Accept": "application/json", "X-Expired": "1"},
+			want:    Problem{Status: 401}, plain: true, wantBody: "Token expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			raw, err := TestRunner(r, fsvr, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var resp fasthttp.Response
+			if err := resp.Read(bufio.NewReader(bytes.NewReader(raw.Body()))); err != nil {
+				t.Fatal(err)
+			}
+
+			isProblem := string(resp.Header.ContentType()) == ContentTypeProblemJson
+			if tt.plain {
+				if isProblem {
+					t.Errorf("!! Unexpected problem document: %s", resp.Body())
+				}
+				if tt.wantBody != "" && string(resp.Body()) != tt.wantBody {
+					t.Errorf("!! Got body %q, want %q", resp.Body(), tt.wantBody)
+				}
+				if tt.want.Status != 0 && resp.StatusCode() != tt.want.Status {
+					t.Errorf("!! Got status %d, want %d", resp.StatusCode(), tt.want.Status)
+				}
+				return
+			}
+			if !isProblem {
+				t.Fatalf("!! Content type: %s, want %s", resp.Header.ContentType(), ContentTypeProblemJson)
+			}
+			var got Problem
+			if err := json.Unmarshal(resp.Body(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || resp.StatusCode() != tt.want.Status {
+				t.Errorf("!! Got problem %#v (status %d), want %#v", got, resp.StatusCode(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNoDefaultContentType(t *testing.T) {
+	r := New(Options{
+		ServerCustomizer: func(s *fasthttp.Server) { s.NoDefaultContentType = true },
+		CustomPanicHandler: func(ctx *fasthttp.RequestCtx, info PanicInfo) {
+			_, _ = ctx.WriteString("Recovered")
+		},
+	})
+	r.Use(func(ctx *fasthttp.RequestCtx) (ok bool) {
+		return len(ctx.Request.Header.Peek("X-Blocked")) == 0
+	}, fasthttp.StatusForbidden)
+	r.Use(func(ctx *fasthttp.RequestCtx) (ok bool) {
+		if len(ctx.Request.Header.Peek("X-Html")) == 0 {
+			return true
+		}
+		ctx.SetContentType("text/html")
+		return false
+	}, fasthttp.StatusUnauthorized)
+	r.Get("/items", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Items")
+	})
+	r.Get("/panic", func(ctx *fasthttp.RequestCtx, params Params) {
+		ctx.SetContentType("text/html")
+		panic("boom")
+	})
+	fsvr := r.newServer(r.PrepareServer())
+
+	tests := []struct {
+		name, method, target string
+		headers              map[string]string
+		wantStatus           int
+		wantContentType      string
+	}{
+		{name: "Not found", method: "GET", target: "/nothing", wantStatus: 404},
+		{name: "Method not allowed", method: "POST", target: "/items", wantStatus: 405},
+		{name: "Middleware failure", method: "GET", target: "/items",
+			headers: map[string]string{"X-Blocked": "1"}, wantStatus: 403},
+		{name: "Unknown method", method: "BREW", target: "/nothing", wantStatus: 405},
+		{name: "Panic", method: "GET", target: "/panic", wantStatus: 500},
+		{name: "Content type set by a middleware", method: "GET", target: "/items",
+			headers:    map[string]string{"Accept": "application/json", "X-Html": "1"},
+			wantStatus: 401, wantContentType: "text/html"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		resp := readResponse(t, r, fsvr, req)
+		if resp.StatusCode() != tt.wantStatus || string(resp.Header.ContentType()) != tt.wantContentType {
+			t.Errorf("%s: status %d, Content-Type %q, want %d %q",
+				tt.name, resp.StatusCode(), resp.Header.ContentType(), tt.wantStatus, tt.wantContentType)
+		}
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	var got PanicInfo
+	r := New()
+	r.Options.CustomPanicHandler = func(ctx *fasthttp.RequestCtx, info PanicInfo) {
+		got = info
+		_, _ = ctx.WriteString("Recovered: " + fmt.Sprint(info.Value))
+	}
+	r.Get("/panic/:id", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("partial output")
+		panic("bad id " + params.ByName("id"))
+	})
+
+	req := httptest.NewRequest("GET", "/panic/7", nil)
+	req.Header.Set(HeaderRequestID, "req-123")
+	resp, err := TestRunner(r, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bd := string(resp.Body())
+	for _, want := range []string{"500 Internal Server Error", "X-Request-Id: req-123", "Recovered: bad id 7"} {
+		if !strings.Contains(bd, want) {
+			t.Errorf("!! Got body: %s,\nShould contain: %s", bd, want)
+		}
+	}
+	if strings.Contains(bd, "partial output") {
+		t.Errorf("!! Output of the panicking handler was not dropped: %s", bd)
+	}
+	if got.Method != "GET" || got.Path != "/panic/7" || got.Pattern != "/panic/:id" || got.RequestID != "req-123" ||
+		!strings.Contains(string(got.Stack), "TestPanicRecovery") {
+		t.Errorf("!! Unexpected panic info: %+v", got)
+	}
+
+	// Panic in a middleware, with the default panic handler
+	r = New()
+	r.Use(func(ctx *fasthttp.RequestCtx) (ok bool) {
+		panic("middleware failure")
+	}, fasthttp.StatusInternalServerError)
+	req = httptest.NewRequest("GET", "/anything", nil)
+	req.Header.Set("Accept", ContentTypeJson)
+	resp, err = TestRunner(r, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bd := string(resp.Body()); !strings.Contains(bd, "500 Internal Server Error") ||
+		!strings.Contains(bd, ContentTypeProblemJson) {
+		t.Errorf("!! Got body: %s,\nShould be a 500 problem document", bd)
+	}
+
+	// Panics in mounted routers go to the nearest panic handler
+	panicHandler := func(name string) PanicHandler {
+		return func(ctx *fasthttp.RequestCtx, info PanicInfo) {
+			_, _ = ctx.WriteString(name + " recovered: " + fmt.Sprint(info.Value))
+		}
+	}
+	panicking := func(ctx *fasthttp.RequestCtx, params Params) {
+		panic("at " + string(ctx.Path()))
+	}
+	r = New(Options{CustomPanicHandler: panicHandler("app")})
+	r.Get("/boom", panicking)
+	admin := New(Options{CustomPanicHandler: panicHandler("admin")})
+	admin.Get("/boom", panicking)
+	r.Mount("/admin", admin)
+	reports := New() // falls back to the panic handler of admin
+	reports.Get("/boom", panicking)
+	admin.Mount("/reports", reports)
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+
+	tests := []struct {
+		target   string
+		wantBody string
+	}{
+		{"/boom", "app recovered: at /boom"},
+		{"/admin/boom", "admin recovered: at /admin/boom"},
+		{"/admin/reports/boom", "admin recovered: at /admin/reports/boom"},
+	}
+	for _, tt := range tests {
+		resp := readResponse(t, r, fsvr, httptest.NewRequest("GET", tt.target, nil))
+		if resp.StatusCode() != fasthttp.StatusInternalServerError || string(resp.Body()) != tt.wantBody {
+			t.Errorf("GET %s: status %d, body %q, want 500 %q", tt.target, resp.StatusCode(), resp.Body(), tt.wantBody)
+		}
+	}
+}
+
 // initTestRox creates a Rox router for testing and initializes it with some routes
 func initTestRox() *Rox {
 	r := New(Options{
-		Verbose: true,
-		Port:    "3020",
+		Verbose:           true,
+		Port:              "3020",
+		AutoMethodOptions: true,
+		AutoHead:          true,
 	})
 
 	var customNotFoundHdlr fasthttp.RequestHandler = func(ctx *fasthttp.RequestCtx) {
@@ -98,6 +629,11 @@ func initTestRox() *Rox {
 	}
 	r.Options.CustomNotFoundHandler = &customNotFoundHdlr
 
+	var customMethodNotAllowedHdlr fasthttp.RequestHandler = func(ctx *fasthttp.RequestCtx) {
+		_, _ = ctx.WriteString("Try another method")
+	}
+	r.Options.CustomMethodNotAllowedHandler = &customMethodNotAllowedHdlr
+
 	// Logging middleware
 	r.Use(
 		func(ctx *fasthttp.RequestCtx) (ok bool) {
@@ -123,6 +659,12 @@ func initTestRox() *Rox {
 	// CORS middleware
 	r.Use(MidWareCors, fasthttp.StatusNotImplemented)
 
+	// Onion style middleware - runs around the route handler
+	r.UseNext(func(ctx *fasthttp.RequestCtx, params Params, next Handler) {
+		next(ctx, params)
+		ctx.Response.Header.Set("X-Handled-Status", fmt.Sprint(ctx.Response.StatusCode()))
+	})
+
 	// Add routes for static files
 	r.AddStaticFilesRoute("/images/", "dist_test/images", 1)
 	r.AddStaticFilesRoute("/css/", "dist_test/css", 1)
@@ -147,7 +689,186 @@ func initTestRox() *Rox {
 	r.Get("/student/:name/class/:className", func(ctx *fasthttp.RequestCtx, params Params) {
 		ctx.Response.Header.Add("Content-Type", "text/html")
 		_, _ = ctx.WriteString(params.ByName("name") + " takes " + params.ByName("className"))
+	}).Name("class")
+	r.Get("/year/:year=^[0-9]{4}$/*path", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Year " + params.ByName("year") + " " + params.ByName("path"))
+	}).Name("archive")
+
+	// Route groups
+	api := r.Group("/api/v1/", MiddleWare{
+		MidFunc: func(ctx *fasthttp.RequestCtx) (ok bool) {
+			ctx.Response.Header.Set("X-Api-Version", "1")
+			return true
+		},
+		FailCode: fasthttp.StatusBadRequest,
+	})
+	api.Get("/", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("API v1")
+	})
+	api.Get("/users/:id", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("User " + params.ByName("id"))
+	})
+
+	admin := r.Group("/admin", MiddleWare{
+		MidFunc: func(ctx *fasthttp.RequestCtx) (ok bool) {
+			return false // pretend the admin auth check failed
+		},
+		FailCode: fasthttp.StatusUnauthorized,
+	})
+	admin.Get("/dashboard", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Admin dashboard")
+	})
+
+	r.GetPost("/items", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Items")
+	})
+
+	// Typed params
+	r.Get("/orders/:id|int", func(ctx *fasthttp.RequestCtx, params Params) {
+		id, err := params.Int("id")
+		if err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		_, _ = ctx.WriteString(fmt.Sprintf("Order #%d", id+1))
+	})
+	r.Get("/orders/:name", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Order named " + params.ByName("name"))
+	})
+	r.Get("/accounts/:uid|uuid", func(ctx *fasthttp.RequestCtx, params Params) {
+		uid, err := params.UUID("uid")
+		if err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		_, _ = ctx.WriteString("Account " + uid.String())
+	})
+	r.Get("/days/:day|date", func(ctx *fasthttp.RequestCtx, params Params) {
+		day, err := params.Time("day", "")
+		if err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		_, _ = ctx.WriteString("Day " + day.Weekday().String())
+	}).Name("day")
+
+	// Error returning handlers
+	r.GetErr("/fail/:kind", func(ctx *fasthttp.RequestCtx, params Params) error {
+		switch params.ByName("kind") {
+		case "teapot":
+			return HTTPError{Code: fasthttp.StatusTeapot, Msg: "short and stout"}
+		case "missing":
+			return fmt.Errorf("no such thing: %w", ErrNotFound)
+		case "invalid":
+			return Validate(struct {
+				Name string `json:"name" validate:"required"`
+			}{})
+		default:
+			return errors.New("boom")
+		}
+	})
+
+	// Per-route middlewares
+	requireRole := func(role string) MiddleWare {
+		return MiddleWare{
+			MidFunc: func(ctx *fasthttp.RequestCtx) (ok bool) {
+				return string(ctx.Request.Header.Peek("X-Role")) == role
+			},
+			FailCode: fasthttp.StatusForbidden,
+		}
+	}
+	reportHeader := MiddleWare{NextFunc: func(ctx *fasthttp.RequestCtx, params Params, next Handler) {
+		ctx.Response.Header.Set("X-Report", params.ByName("kind"))
+		next(ctx, params)
+	}}
+	r.Get("/reports/private", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Private report")
+	}, requireRole("admin"))
+	r.Get("/reports/:kind", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Report " + params.ByName("kind"))
+	}, reportHeader)
+
+	// Mounted sub-router
+	shop := New()
+	shop.Use(func(ctx *fasthttp.RequestCtx) (ok bool) {
+		ctx.Response.Header.Set("X-Shop", "yes")
+		return true
+	}, fasthttp.StatusInternalServerError)
+	var shopNotFoundHdlr fasthttp.RequestHandler = func(ctx *fasthttp.RequestCtx) {
+		_, _ = ctx.WriteString("Shop item not found")
+	}
+	shop.Options.CustomNotFoundHandler = &shopNotFoundHdlr
+	shop.Options.CustomErrorHandler = func(ctx *fasthttp.RequestCtx, err error) {
+		ctx.Error("Shop failed: "+err.Error(), fasthttp.StatusInternalServerError)
+	}
+	shop.GetErr("/fail", func(ctx *fasthttp.RequestCtx, params Params) error {
+		return errors.New("out of stock")
 	})
+	shop.AddStaticFilesRoute("/css/", "dist_test/css", 1)
+	shop.Get("/", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Shop home")
+	})
+	shop.Get("/items/:id", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Item " + params.ByName("id"))
+	}).Name("item")
+	r.Mount("/shop", shop)
 
 	return r
 }
+
+func TestMountRoot(t *testing.T) {
+	var notFound fasthttp.RequestHandler = func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusNotFound)
+		_, _ = ctx.WriteString("Module not found")
+	}
+	module := New(Options{CustomNotFoundHandler: &notFound})
+	module.Get("/status", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Module status")
+	})
+	r := New()
+	r.Mount("/", module)
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/status", fasthttp.StatusOK, "Module status"},
+		{"/nothing", fasthttp.StatusNotFound, "Module not found"},
+	}
+	for _, tt := range tests {
+		resp := readResponse(t, r, fsvr, httptest.NewRequest("GET", tt.target, nil))
+		if resp.StatusCode() != tt.wantStatus || string(resp.Body()) != tt.wantBody {
+			t.Errorf("GET %s: status %d, body %q, want %d %q", tt.target, resp.StatusCode(), resp.Body(), tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+// readResponse runs the request through TestRunner and parses the raw response
+func readResponse(t *testing.T, r *Rox, s *fasthttp.Server, req *http.Request) *fasthttp.Response {
+	t.Helper()
+	raw, err := TestRunner(r, s, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &fasthttp.Response{SkipBody: req.Method == http.MethodHead}
+	resp.Header.SetNoDefaultContentType(true) // report the Content-Type as sent
+	if err := resp.Read(bufio.NewReader(bytes.NewReader(raw.Body()))); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// Pattern routes are dispatched whether or not Options.Verbose is set
+func TestPatternRouteWithoutVerbose(t *testing.T) {
+	r := New(Options{Verbose: false})
+	r.Get("/greet/:name", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("Hey " + params.ByName("name"))
+	})
+
+	resp := readResponse(t, r, nil, httptest.NewRequest("GET", "/greet/sue", nil))
+	if resp.StatusCode() != fasthttp.StatusOK || string(resp.Body()) != "Hey sue" {
+		t.Errorf("GET /greet/sue: status %d, body %q", resp.StatusCode(), resp.Body())
+	}
+}
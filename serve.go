@@ -0,0 +1,385 @@
+package rox
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// lifecycle holds the state of a running server
+type lifecycle struct {
+	mu         sync.Mutex
+	listeners  []*listener // the main listener first
+	certs      *certStore  // the TLS certificates in use
+	admins     []adminListener
+	onStart    []func() error
+	onShutdown []func(ctx context.Context) error
+	started    int32 // atomic
+	draining   int32 // atomic
+}
+
+// listener is a listener served by its own fasthttp.Server
+type listener struct {
+	name   string
+	ln     net.Listener
+	server *fasthttp.Server
+}
+
+// adminListener is a separate listener carrying the routes of another router
+type adminListener struct {
+	addr   string
+	router *Rox
+}
+
+// Serve starts the server and blocks until it is stopped by SIGINT or SIGTERM.
+// In-flight requests are then drained as described in ServeContext
+func (r *Rox) Serve() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := r.ServeContext(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ServeContext starts the server and blocks until ctx is done or the server fails.
+// When ctx is done the server is shut down gracefully: readiness fails for Options.DrainPeriod,
+// then the listeners are closed and in-flight requests get up to Options.ShutdownTimeout to complete
+func (r *Rox) ServeContext(ctx context.Context) error {
+	handler := r.PrepareServer()
+
+	ln, err := r.listen()
+	if err != nil {
+		return err
+	}
+	return r.serveContext(ctx, handler, ln)
+}
+
+// ServeListener serves on an already opened listener until Shutdown is called.
+// The listener is closed on shutdown
+func (r *Rox) ServeListener(ln net.Listener) error {
+	return r.serveContext(context.Background(), r.PrepareServer(), ln)
+}
+
+// ServeUnix serves on a Unix domain socket until Shutdown is called.
+// Any existing file at path is removed first, and the socket file gets the given mode
+func (r *Rox) ServeUnix(path string, mode os.FileMode) error {
+	handler := r.PrepareServer()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rox: unexpected error when trying to remove unix socket file %q - %w", path, err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		return fmt.Errorf("rox: cannot chmod %#o for %q - %w", mode, path, err)
+	}
+	return r.serveContext(context.Background(), handler, ln)
+}
+
+// AddAdminListener serves the routes of the admin router on a separate plain HTTP address,
+// eg for metrics, pprof and health checks. Admin listeners start and stop with this router's server.
+// To report this server's readiness, register r.ReadyHandler on the admin router
+// Example:
+//
+//	admin := rox.New()
+//	admin.Get("/readyz", r.ReadyHandler)
+//	r.AddAdminListener("127.0.0.1:9090", admin)
+func (r *Rox) AddAdminListener(addr string, admin *Rox) {
+	if admin == nil || admin == r {
+		panic("router: invalid admin router")
+	}
+	r.admins = append(r.admins, adminListener{addr: addr, router: admin})
+}
+
+// serveContext serves the main listener, and any additional listeners, until ctx is done.
+// See ServeContext
+func (r *Rox) serveContext(ctx context.Context, handler fasthttp.RequestHandler, ln net.Listener) error {
+	tlsConfig, err := r.tlsConfig()
+	if err != nil {
+		_ = ln.Close()
+		return err
+	}
+	mainLn := ln
+	if tlsConfig != nil {
+		mainLn = tls.NewListener(ln, tlsConfig)
+
+		if tlsOpts := r.Options.TLS; tlsOpts.ReloadInterval > 0 || tlsOpts.ReloadOnSIGHUP {
+			watchCtx, stopWatch := context.WithCancel(context.Background())
+			defer stopWatch()
+			go r.certs.watch(watchCtx, tlsOpts.ReloadInterval, tlsOpts.ReloadOnSIGHUP)
+		}
+	}
+
+	listeners := []*listener{{name: "main", ln: mainLn, server: r.newServer(handler)}}
+	extras, err := r.openExtraListeners(handler, ln)
+	if err != nil {
+		_ = ln.Close()
+		return err
+	}
+	listeners = append(listeners, extras...)
+
+	r.mu.Lock()
+	r.listeners = listeners
+	stopped := r.Draining() // Shutdown was called before there were listeners to close
+	r.mu.Unlock()
+	if stopped {
+		for _, l := range listeners {
+			_ = l.ln.Close()
+		}
+		return nil
+	}
+
+	for _, fn := range r.onStart {
+		if err := fn(); err != nil {
+			for _, l := range listeners {
+				_ = l.ln.Close()
+			}
+			return fmt.Errorf("rox: start hook - %w", err)
+		}
+	}
+
+	errCh := make(chan error, len(listeners))
+	for _, l := range listeners {
+		if r.Options.Verbose {
+			fmt.Println("Rox listening on:", l.ln.Addr(), "("+l.name+")")
+		}
+		go func(l *listener) {
+			if err := l.server.Serve(l.ln); err != nil {
+				errCh <- fmt.Errorf("rox: %s listener %s - %w", l.name, l.ln.Addr(), err)
+				return
+			}
+			errCh <- nil
+		}(l)
+	}
+	atomic.StoreInt32(&r.started, 1)
+
+	var serveErr error
+	select {
+	case serveErr = <-errCh: // stopped by a direct call to Shutdown, or failed
+		if serveErr == nil {
+			return waitServers(errCh, len(listeners)-1)
+		}
+	case <-ctx.Done():
+	}
+
+	timeout := r.Options.ShutdownTimeout
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := r.Shutdown(shutdownCtx); err != nil && serveErr == nil {
+		serveErr = err
+	}
+	if serveErr != nil { // one listener failed, the others were shut down
+		_ = waitServers(errCh, len(listeners)-1)
+		return serveErr
+	}
+	return waitServers(errCh, len(listeners))
+}
+
+// waitServers waits for n servers to stop and returns the first error
+func waitServers(errCh <-chan error, n int) (err error) {
+	for i := 0; i < n; i++ {
+		if e := <-errCh; e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// openExtraListeners opens the plain HTTP and redirect listeners of a TLS server and the admin listeners.
+// mainLn is the listener of the TLS server
+func (r *Rox) openExtraListeners(handler fasthttp.RequestHandler, mainLn net.Listener) (listeners []*listener, err error) {
+	defer func() {
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.ln.Close()
+			}
+		}
+	}()
+
+	if r.Options.TLS.UseTLS && r.Options.TLS.HTTPAddr != "" {
+		ln, err := net.Listen("tcp", r.Options.TLS.HTTPAddr)
+		if err != nil {
+			return listeners, err
+		}
+		listeners = append(listeners, &listener{name: "http", ln: ln, server: r.newServer(handler)})
+	}
+
+	if r.Options.TLS.UseTLS && r.Options.TLS.RedirectAddr != "" {
+		ln, err := net.Listen("tcp", r.Options.TLS.RedirectAddr)
+		if err != nil {
+			return listeners, err
+		}
+		listeners = append(listeners,
+			&listener{name: "redirect", ln: ln, server: r.newServer(r.redirectHandler(mainLn.Addr()))})
+	}
+
+	for _, al := range r.admins {
+		ln, err := net.Listen("tcp", al.addr)
+		if err != nil {
+			return listeners, err
+		}
+		listeners = append(listeners,
+			&listener{name: "admin", ln: ln, server: al.router.newServer(al.router.PrepareServer())})
+	}
+	return listeners, nil
+}
+
+// newServer builds a fasthttp.Server for the handler from Options.Server and Options.ServerCustomizer
+func (r *Rox) newServer(handler fasthttp.RequestHandler) *fasthttp.Server {
+	so := r.Options.Server
+	srv := &fasthttp.Server{
+		Handler:            handler,
+		Name:               so.Name,
+		ReadTimeout:        so.ReadTimeout,
+		WriteTimeout:       so.WriteTimeout,
+		IdleTimeout:        so.IdleTimeout,
+		MaxRequestBodySize: so.MaxRequestBodySize,
+		Concurrency:        so.Concurrency,
+		ReadBufferSize:     so.ReadBufferSize,
+		WriteBufferSize:    so.WriteBufferSize,
+		ReduceMemoryUsage:  so.ReduceMemoryUsage,
+		StreamRequestBody:  so.StreamRequestBody,
+		DisableKeepalive:   so.DisableKeepalive,
+		Logger:             so.Logger,
+	}
+	if r.Options.ServerCustomizer != nil {
+		r.Options.ServerCustomizer(srv)
+	}
+	return srv
+}
+
+// listen opens the TCP listener on Options.Addr, or else on Options.Port of all IPv4 interfaces
+func (r *Rox) listen() (net.Listener, error) {
+	if r.Options.Addr != "" {
+		return net.Listen("tcp", r.Options.Addr)
+	}
+	if r.Options.TLS.UseTLS {
+		return net.Listen("tcp4", ipAny+":"+r.Options.Port)
+	}
+	return net.Listen("tcp4", ":"+r.Options.Port)
+}
+
+// Addr returns the address of the main listener, or nil before the server listens.
+// This is how to find the port chosen for an Options.Addr such as "127.0.0.1:0"
+func (r *Rox) Addr() net.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.listeners) == 0 {
+		return nil
+	}
+	return r.listeners[0].ln.Addr()
+}
+
+// Addrs returns the addresses of all the listeners: the main one first,
+// then the plain HTTP and the redirect ones of a TLS server, if any, then the admin ones in the order they were added
+func (r *Rox) Addrs() (addrs []net.Addr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, l := range r.listeners {
+		addrs = append(addrs, l.ln.Addr())
+	}
+	return
+}
+
+// Shutdown gracefully stops the server: readiness fails for Options.DrainPeriod,
+// then all the listeners are closed and in-flight requests are awaited until ctx is done.
+// The shutdown hooks run last, even if ctx is done
+func (r *Rox) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&r.draining, 1)
+
+	if r.Options.DrainPeriod > 0 {
+		timer := time.NewTimer(r.Options.DrainPeriod)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	r.mu.Lock()
+	listeners := r.listeners
+	r.mu.Unlock()
+
+	errs := make([]error, len(listeners))
+	var wg sync.WaitGroup
+	for i, l := range listeners {
+		wg.Add(1)
+		go func(i int, l *listener) {
+			defer wg.Done()
+			errs[i] = l.server.ShutdownWithContext(ctx)
+			// The server does not know its listener until Serve runs, so close it too.
+			// Serve then returns as soon as it starts
+			_ = l.ln.Close()
+		}(i, l)
+	}
+	wg.Wait()
+
+	var err error
+	for _, e := range errs {
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	for _, fn := range r.onShutdown {
+		if hookErr := fn(ctx); hookErr != nil {
+			log.Println("Shutdown hook error:", hookErr)
+			if err == nil {
+				err = fmt.Errorf("rox: shutdown hook - %w", hookErr)
+			}
+		}
+	}
+	return err
+}
+
+// OnStart adds a hook run once the server is listening, before it serves requests.
+// An error aborts serving
+func (r *Rox) OnStart(fn func() error) {
+	r.onStart = append(r.onStart, fn)
+}
+
+// OnShutdown adds a hook run once the server has stopped serving requests, eg to close databases.
+// ctx bounds the shutdown
+func (r *Rox) OnShutdown(fn func(ctx context.Context) error) {
+	r.onShutdown = append(r.onShutdown, fn)
+}
+
+// Ready reports whether the server is serving and not shutting down
+func (r *Rox) Ready() bool {
+	return atomic.LoadInt32(&r.started) == 1 && !r.Draining()
+}
+
+// Draining reports whether the server is shutting down
+func (r *Rox) Draining() bool {
+	return atomic.LoadInt32(&r.draining) == 1
+}
+
+// ReadyHandler is a readiness check handler: 200 when Ready, else 503
+// Example:
+//
+//	r.Get("/readyz", r.ReadyHandler)
+func (r *Rox) ReadyHandler(ctx *fasthttp.RequestCtx, _ Params) {
+	if !r.Ready() {
+		ctx.Error("not ready", fasthttp.StatusServiceUnavailable)
+		return
+	}
+	_, _ = ctx.WriteString("ok")
+}
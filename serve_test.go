@@ -0,0 +1,609 @@
+package rox
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	r := New(Options{Addr: "127.0.0.1:0", DrainPeriod: 500 * time.Millisecond})
+	inFlight, release := make(chan struct{}), make(chan struct{})
+	r.Get("/slow", func(ctx *fasthttp.RequestCtx, params Params) {
+		close(inFlight)
+		<-release // held until the drain is checked
+		_, _ = ctx.WriteString("done")
+	})
+	r.Get("/readyz", r.ReadyHandler)
+
+	started := make(chan string, 1)
+	r.OnStart(func() error {
+		started <- r.Addr().String()
+		return nil
+	})
+	var shutdownHooks int32
+	r.OnShutdown(func(ctx context.Context) error {
+		atomic.AddInt32(&shutdownHooks, 1)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- r.ServeContext(ctx)
+	}()
+	addr := <-started
+
+	if status, _, err := fasthttp.Get(nil, "http://"+addr+"/readyz"); err != nil || status != fasthttp.StatusOK {
+		t.Fatalf("readiness before shutdown: status %d, err %v", status, err)
+	}
+
+	type result struct {
+		body string
+		err  error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		_, body, err := fasthttp.Get(nil, "http://"+addr+"/slow")
+		slow <- result{string(body), err}
+	}()
+	<-inFlight
+
+	cancel()
+	waitFor(t, "Ready() to be false while draining", func() bool { return !r.Ready() })
+	if status, _, err := fasthttp.Get(nil, "http://"+addr+"/readyz"); err != nil || status != fasthttp.StatusServiceUnavailable {
+		t.Errorf("readiness while draining: status %d, err %v", status, err)
+	}
+	close(release)
+
+	if res := <-slow; res.err != nil || res.body != "done" {
+		t.Errorf("in-flight request: body %q, err %v", res.body, res.err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("ServeContext() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeContext() did not return after shutdown")
+	}
+	if n := atomic.LoadInt32(&shutdownHooks); n != 1 {
+		t.Errorf("shutdown hook ran %d times, want 1", n)
+	}
+}
+
+func TestShutdownWhileStarting(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		r := New(Options{Addr: "127.0.0.1:0"})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel() // done before the listeners are served
+		served := make(chan error, 1)
+		go func() {
+			served <- r.ServeContext(ctx)
+		}()
+		select {
+		case err := <-served:
+			if err != nil {
+				t.Fatalf("ServeContext() error = %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("ServeContext() with a done context did not return")
+		}
+	}
+
+	// Shutdown right after ServeListener, maybe before it serves
+	for i := 0; i < 20; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := New()
+		served := make(chan error, 1)
+		go func() {
+			served <- r.ServeListener(ln)
+		}()
+		if err = r.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown() error = %v", err)
+		}
+		select {
+		case err := <-served:
+			if err != nil {
+				t.Fatalf("ServeListener() error = %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("ServeListener() did not return after Shutdown")
+		}
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for " + what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	r := New(Options{
+		Server: ServerOpts{MaxRequestBodySize: 16},
+		ServerCustomizer: func(s *fasthttp.Server) {
+			s.Name = "rox-test"
+		},
+	})
+	r.Post("/echo", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.Write(ctx.PostBody())
+	})
+
+	resp, err := TestRunner(r, nil, httptest.NewRequest("POST", "/echo", strings.NewReader("small")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bd := string(resp.Body()); !strings.Contains(bd, "Server: rox-test") {
+		t.Errorf("!! Got body: %s,\nShould contain the customized server name", bd)
+	}
+
+	// The server rejects the request before it reaches the router
+	_, err = TestRunner(r, nil, httptest.NewRequest("POST", "/echo", strings.NewReader(strings.Repeat("large", 10))))
+	if err == nil || !strings.Contains(err.Error(), "body size exceeds the given limit") {
+		t.Errorf("TestRunner() error = %v, want a body size error", err)
+	}
+}
+
+func TestServeUnix(t *testing.T) {
+	r := New()
+	r.Get("/ping", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("pong")
+	})
+
+	sock := filepath.Join(t.TempDir(), "rox.sock")
+	started := make(chan struct{})
+	r.OnStart(func() error {
+		close(started)
+		return nil
+	})
+	served := make(chan error, 1)
+	go func() {
+		served <- r.ServeUnix(sock, 0600)
+	}()
+	select {
+	case <-started:
+	case err := <-served:
+		t.Fatalf("ServeUnix() error = %v", err)
+	}
+
+	if addr := r.Addr(); addr == nil || addr.Network() != "unix" {
+		t.Errorf("Addr() = %v, want the unix socket", addr)
+	}
+
+	client := &fasthttp.Client{Dial: func(addr string) (net.Conn, error) {
+		return net.Dial("unix", sock)
+	}}
+	status, body, err := client.Get(nil, "http://rox/ping")
+	if err != nil || status != fasthttp.StatusOK || string(body) != "pong" {
+		t.Errorf("GET over unix socket: status %d, body %q, err %v", status, body, err)
+	}
+
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("ServeUnix() error = %v", err)
+	}
+}
+
+func TestMultipleListeners(t *testing.T) {
+	certPEM, keyPEM := selfSignedCert(t, "localhost")
+	r := New(Options{
+		Addr: "127.0.0.1:0",
+		TLS:  TLSOpts{UseTLS: true, CertData: certPEM, KeyData: keyPEM, HTTPAddr: "127.0.0.1:0"},
+	})
+	r.Get("/ping", func(ctx *fasthttp.RequestCtx, params Params) {
+		if ctx.IsTLS() {
+			_, _ = ctx.WriteString("pong tls")
+			return
+		}
+		_, _ = ctx.WriteString("pong")
+	})
+
+	admin := New()
+	admin.Get("/readyz", r.ReadyHandler)
+	r.AddAdminListener("127.0.0.1:0", admin)
+
+	started := make(chan []net.Addr, 1)
+	r.OnStart(func() error {
+		started <- r.Addrs()
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- r.ServeContext(ctx)
+	}()
+	var addrs []net.Addr
+	select {
+	case addrs = <-started:
+	case err := <-served:
+		t.Fatalf("ServeContext() error = %v", err)
+	}
+	if len(addrs) != 3 {
+		t.Fatalf("Addrs() = %v, want 3 listeners", addrs)
+	}
+
+	client := &fasthttp.Client{TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	tests := []struct {
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{"https://" + addrs[0].String() + "/ping", fasthttp.StatusOK, "pong tls"},
+		{"http://" + addrs[1].String() + "/ping", fasthttp.StatusOK, "pong"},
+		{"http://" + addrs[2].String() + "/readyz", fasthttp.StatusOK, "ok"},
+		// The admin listener carries only the admin routes
+		{"http://" + addrs[2].String() + "/ping", fasthttp.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		status, body, err := client.Get(nil, tt.url)
+		if err != nil || status != tt.wantStatus || (tt.wantBody != "" && string(body) != tt.wantBody) {
+			t.Errorf("GET %s: status %d, body %q, err %v", tt.url, status, body, err)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("ServeContext() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeContext() did not return after shutdown")
+	}
+	for _, addr := range addrs {
+		if conn, err := net.Dial("tcp", addr.String()); err == nil {
+			_ = conn.Close()
+			t.Errorf("listener %s still accepts connections after shutdown", addr)
+		}
+	}
+}
+
+func TestRedirectListener(t *testing.T) {
+	certPEM, keyPEM := selfSignedCert(t, "localhost")
+	webroot := t.TempDir()
+	challengeDir := filepath.Join(webroot, ".well-known", "acme-challenge")
+	if err := os.MkdirAll(challengeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(challengeDir, "token123"), []byte("token123.key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New(Options{
+		Addr: "127.0.0.1:0",
+		TLS: TLSOpts{UseTLS: true, CertData: certPEM, KeyData: keyPEM,
+			RedirectAddr: "127.0.0.1:0", ACMEChallengeRoot: webroot},
+	})
+	r.Get("/ping", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("pong")
+	})
+
+	started := make(chan []net.Addr, 1)
+	r.OnStart(func() error {
+		started <- r.Addrs()
+		return nil
+	})
+	served := make(chan error, 1)
+	go func() {
+		served <- r.ServeContext(context.Background())
+	}()
+	var addrs []net.Addr
+	select {
+	case addrs = <-started:
+	case err := <-served:
+		t.Fatalf("ServeContext() error = %v", err)
+	}
+	defer func() {
+		_ = r.Shutdown(context.Background())
+		<-served
+	}()
+	httpsOrigin := "https://" + addrs[0].String()
+	redirectOrigin := "http://" + addrs[1].String()
+
+	tests := []struct {
+		method       string
+		url          string
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{"GET", redirectOrigin + "/ping?a=1", fasthttp.StatusMovedPermanently, httpsOrigin + "/ping?a=1", ""},
+		{"POST", redirectOrigin + "/ping", fasthttp.StatusPermanentRedirect, httpsOrigin + "/ping", ""},
+		{"GET", redirectOrigin + "/.well-known/acme-challenge/token123", fasthttp.StatusOK, "", "token123.key"},
+		{"GET", redirectOrigin + "/.well-known/acme-challenge/missing", fasthttp.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+		req.Header.SetMethod(tt.method)
+		req.SetRequestURI(tt.url)
+		if err := fasthttp.Do(req, resp); err != nil {
+			t.Errorf("%s %s: err %v", tt.method, tt.url, err)
+			continue
+		}
+		if resp.StatusCode() != tt.wantStatus {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.url, resp.StatusCode(), tt.wantStatus)
+		}
+		if loc := string(resp.Header.Peek("Location")); loc != tt.wantLocation {
+			t.Errorf("%s %s: Location %q, want %q", tt.method, tt.url, loc, tt.wantLocation)
+		}
+		if tt.wantBody != "" && string(resp.Body()) != tt.wantBody {
+			t.Errorf("%s %s: body %q, want %q", tt.method, tt.url, resp.Body(), tt.wantBody)
+		}
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}
+
+	client := &fasthttp.Client{TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	if status, body, err := client.Get(nil, httpsOrigin+"/ping"); err != nil || status != fasthttp.StatusOK || string(body) != "pong" {
+		t.Errorf("GET over TLS: status %d, body %q, err %v", status, body, err)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		redirectPort string
+		tlsPort      int
+		host         string
+		wantLocation string
+	}{
+		{"Default port", "", 443, "example.com", "https://example.com/x?a=1"},
+		{"Listener port", "", 8443, "example.com:80", "https://example.com:8443/x?a=1"},
+		{"Public port", "443", 8443, "example.com", "https://example.com/x?a=1"},
+		{"Forwarded port", "9443", 8443, "example.com", "https://example.com:9443/x?a=1"},
+		{"IPv6", "", 443, "[::1]", "https://[::1]/x?a=1"},
+		{"IPv6 with port", "", 8443, "[::1]:80", "https://[::1]:8443/x?a=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(Options{TLS: TLSOpts{UseTLS: true, RedirectPort: tt.redirectPort}})
+			handler := r.redirectHandler(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: tt.tlsPort})
+			var ctx fasthttp.RequestCtx
+			ctx.Request.SetRequestURI("/x?a=1")
+			ctx.Request.Header.SetHost(tt.host)
+			handler(&ctx)
+			if loc := string(ctx.Response.Header.Peek("Location")); loc != tt.wantLocation {
+				t.Errorf("Location %q, want %q", loc, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestTLSCertificates(t *testing.T) {
+	dir := t.TempDir()
+	writeCert := func(name string, hosts ...string) (certFile, keyFile string) {
+		certPEM, keyPEM := selfSignedCert(t, hosts...)
+		certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+		if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	aCert, aKey := writeCert("a", "a.test")
+	bCert, bKey := writeCert("b", "b.test", "*.b.test")
+
+	r := New(Options{
+		Addr: "127.0.0.1:0",
+		TLS: TLSOpts{UseTLS: true, CertFile: aCert, KeyFile: aKey,
+			Certs: []Cert{{CertFile: bCert, KeyFile: bKey}}, ReloadInterval: 10 * time.Millisecond},
+	})
+	r.Get("/ping", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("pong")
+	})
+	started := make(chan string, 1)
+	r.OnStart(func() error {
+		started <- r.Addr().String()
+		return nil
+	})
+	served := make(chan error, 1)
+	go func() {
+		served <- r.ServeContext(context.Background())
+	}()
+	var addr string
+	select {
+	case addr = <-started:
+	case err := <-served:
+		t.Fatalf("ServeContext() error = %v", err)
+	}
+	defer func() {
+		_ = r.Shutdown(context.Background())
+		<-served
+	}()
+
+	// peerCert returns the certificate the server presents for the SNI server name
+	peerCert := func(serverName string) *x509.Certificate {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("TLS handshake for %q: %v", serverName, err)
+		}
+		defer func() { _ = conn.Close() }()
+		return conn.ConnectionState().PeerCertificates[0]
+	}
+
+	tests := []struct {
+		serverName string
+		wantCN     string
+	}{
+		{"a.test", "a.test"},
+		{"b.test", "b.test"},
+		{"WWW.B.TEST", "b.test"},
+		{"unknown.test", "a.test"}, // the default certificate
+		{"", "a.test"},
+	}
+	for _, tt := range tests {
+		if cn := peerCert(tt.serverName).Subject.CommonName; cn != tt.wantCN {
+			t.Errorf("certificate for %q: CN %q, want %q", tt.serverName, cn, tt.wantCN)
+		}
+	}
+
+	// Renew the default certificate on disk; it is picked up without a restart
+	oldSerial := peerCert("a.test").SerialNumber
+	writeCert("a", "a.test")
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(aCert, future, future)
+	waitFor(t, "the renewed certificate to be reloaded", func() bool {
+		return peerCert("a.test").SerialNumber.Cmp(oldSerial) != 0
+	})
+
+	// A broken renewal keeps the certificates in use
+	if err := os.WriteFile(bCert, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReloadCertificates(); err == nil {
+		t.Error("ReloadCertificates() with a broken certificate should fail")
+	}
+	if cn := peerCert("b.test").Subject.CommonName; cn != "b.test" {
+		t.Errorf("after a failed reload, certificate CN %q, want b.test", cn)
+	}
+
+	client := &fasthttp.Client{TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	if status, body, err := client.Get(nil, "https://"+addr+"/ping"); err != nil || status != fasthttp.StatusOK || string(body) != "pong" {
+		t.Errorf("GET over TLS: status %d, body %q, err %v", status, body, err)
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	certPEM, keyPEM := selfSignedCert(t, "localhost")
+	clientCertPEM, clientKeyPEM := selfSignedCert(t, "billing.internal")
+	otherCertPEM, otherKeyPEM := selfSignedCert(t, "intruder.internal")
+
+	r := New(Options{
+		Addr: "127.0.0.1:0",
+		TLS: TLSOpts{UseTLS: true, CertData: certPEM, KeyData: keyPEM,
+			ClientAuth: ClientAuthVerifyIfGiven, ClientCAData: clientCertPEM},
+	})
+	r.Get("/whoami", func(ctx *fasthttp.RequestCtx, params Params) {
+		id := ClientIdentity(ctx)
+		_, _ = ctx.WriteString(id.Subject.CommonName + " " + strings.Join(id.DNSNames, ",") + " " + id.Fingerprint)
+	}, RequireClientCert())
+	r.Get("/public", func(ctx *fasthttp.RequestCtx, params Params) {
+		_, _ = ctx.WriteString("public")
+	})
+
+	started := make(chan string, 1)
+	r.OnStart(func() error {
+		started <- r.Addr().String()
+		return nil
+	})
+	served := make(chan error, 1)
+	go func() {
+		served <- r.ServeContext(context.Background())
+	}()
+	var addr string
+	select {
+	case addr = <-started:
+	case err := <-served:
+		t.Fatalf("ServeContext() error = %v", err)
+	}
+	defer func() {
+		_ = r.Shutdown(context.Background())
+		<-served
+	}()
+
+	clientFor := func(certPEM, keyPEM []byte) *fasthttp.Client {
+		cfg := &tls.Config{InsecureSkipVerify: true}
+		if certPEM != nil {
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.Certificates = []tls.Certificate{cert}
+		}
+		return &fasthttp.Client{TLSConfig: cfg}
+	}
+	block, _ := pem.Decode(clientCertPEM)
+	sum := sha256.Sum256(block.Bytes)
+	wantIdentity := "billing.internal billing.internal " + hex.EncodeToString(sum[:])
+
+	if status, body, err := clientFor(clientCertPEM, clientKeyPEM).Get(nil, "https://"+addr+"/whoami"); err != nil ||
+		status != fasthttp.StatusOK || string(body) != wantIdentity {
+		t.Errorf("GET /whoami with a client certificate: status %d, body %q, err %v", status, body, err)
+	}
+	if status, _, err := clientFor(nil, nil).Get(nil, "https://"+addr+"/whoami"); err != nil || status != fasthttp.StatusUnauthorized {
+		t.Errorf("GET /whoami without a client certificate: status %d, err %v", status, err)
+	}
+	if status, body, err := clientFor(nil, nil).Get(nil, "https://"+addr+"/public"); err != nil ||
+		status != fasthttp.StatusOK || string(body) != "public" {
+		t.Errorf("GET /public without a client certificate: status %d, body %q, err %v", status, body, err)
+	}
+	untrusted := clientFor(otherCertPEM, otherKeyPEM)
+	untrusted.TLSConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return &untrusted.TLSConfig.Certificates[0], nil // send it even though the server does not accept its issuer
+	}
+	if _, _, err := untrusted.Get(nil, "https://"+addr+"/public"); err == nil {
+		t.Error("GET with an untrusted client certificate should fail the handshake")
+	}
+
+	// Verification needs client CAs
+	r2 := New(Options{Addr: "127.0.0.1:0",
+		TLS: TLSOpts{UseTLS: true, CertData: certPEM, KeyData: keyPEM, ClientAuth: ClientAuthRequire}})
+	if err := r2.ServeContext(context.Background()); err == nil || !strings.Contains(err.Error(), "ClientCAFile") {
+		t.Errorf("ServeContext() without client CAs: error = %v", err)
+	}
+}
+
+// selfSignedCert generates a PEM encoded self-signed certificate and key for the hosts
+func selfSignedCert(t *testing.T, hosts ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
@@ -0,0 +1,145 @@
+package rox
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type AssetPath struct {
+	Prefix         []byte       // url prefix
+	FileSystemRoot string       // file locations
+	StripSlashes   int          // how many slash words to strip from the url prefix
+	Opts           StaticOpts   // how files are served
+	middlewares    []MiddleWare // middlewares of a mounted sub-router
+	fsys           fs.FS        // files of a route added with AddStaticFS, instead of FileSystemRoot
+	exclude        [][]byte     // Opts.Exclude
+	handler        fasthttp.RequestHandler
+	// hashed maps content-hashed url paths to the url paths of the files served for them
+	hashed map[string]string
+}
+
+// StaticOpts are the options of a static files route
+type StaticOpts struct {
+	Compress           bool          // compress files on the fly per Accept-Encoding, caching the results next to the files
+	CacheDuration      time.Duration // how long inactive open files are cached, 10s if zero
+	AcceptByteRange    bool          // serve Range requests
+	IndexNames         []string      // files served for a directory, eg "index.html"
+	GenerateIndexPages bool          // list directories which have no index file
+	// SPAFallback serves the index file, the first of IndexNames or else index.html, with a 200
+	// for GET and HEAD requests of missing files, so that deep links of a single-page application work
+	SPAFallback bool
+	// Exclude are URL path prefixes under the route prefix, eg "/app/api/", which are left to the router
+	Exclude []string
+	// Fingerprint hashes the files when the server is prepared and also serves them at content-hashed URLs,
+	// eg /css/sample.3f9a1c2e.css for /css/sample.css, with immutable caching. See Rox.Asset
+	Fingerprint bool
+	// Precompressed serves the .br or .gz sibling of a file, eg app.js.br for app.js, when the client accepts it.
+	// Otherwise the file is compressed on the fly if Compress is set, or else served as is
+	Precompressed bool
+	// Manifest is the name, in the route files, of a JSON build manifest mapping file names
+	// to the names of their content-hashed copies, eg {"css/sample.css": "css/sample.3f9a1c2e.css"}.
+	// The copies are served with immutable caching. See Rox.Asset
+	Manifest string
+}
+
+// defaultStaticOpts are the options of a static files route added without options
+var defaultStaticOpts = StaticOpts{
+	AcceptByteRange:    true,
+	IndexNames:         []string{"index.html"},
+	GenerateIndexPages: true,
+}
+
+// Add a route to static files
+// Prefix is a starting portion of the URL delimited by slashes
+// fsRoot is the path to the top-level folder to serve files from
+// StripSlashes is the number of slash delimited tokens to remove from the URL
+// before appending it to the fsRoot to form the full file path
+// opts optionally replaces the default options: byte ranges, index.html and directory listings
+// Example: rx.AddStaticFilesRoute("/images/", "artifacts/images", 1)
+func (r *Rox) AddStaticFilesRoute(prefix, fsRoot string, slashesToStrip int, opts ...StaticOpts) {
+	ap := AssetPath{Prefix: []byte(prefix), FileSystemRoot: fsRoot, StripSlashes: slashesToStrip, Opts: defaultStaticOpts}
+	if len(opts) > 0 {
+		ap.Opts = opts[0]
+	}
+	r.Options.assetPaths = append(r.Options.assetPaths, ap)
+}
+
+// initAssetHandlers builds the file handler of each static files route, once,
+// so that the open file and compressed file caches are kept across requests
+func (r *Rox) initAssetHandlers() {
+	for i := range r.Options.assetPaths {
+		ap := &r.Options.assetPaths[i]
+		if ap.handler != nil {
+			continue
+		}
+		ap.exclude = ap.exclude[:0]
+		for _, prefix := range ap.Opts.Exclude {
+			ap.exclude = append(ap.exclude, []byte(prefix))
+		}
+
+		var fsHandler fasthttp.RequestHandler
+		if ap.fsys != nil {
+			fsHandler = newFSHandler(ap)
+		} else {
+			fsHandler = newDiskHandler(ap)
+		}
+		if ap.Opts.Precompressed {
+			fsHandler = newPrecompressedHandler(ap, fsHandler)
+		}
+		if ap.Opts.Fingerprint || ap.Opts.Manifest != "" {
+			fsHandler = r.fingerprint(ap, fsHandler)
+		}
+
+		if len(ap.middlewares) > 0 {
+			h := chain(func(ctx *fasthttp.RequestCtx, _ Params) { fsHandler(ctx) }, ap.middlewares)
+			ap.handler = func(ctx *fasthttp.RequestCtx) { h(ctx, Params{}) }
+		} else {
+			ap.handler = fsHandler
+		}
+	}
+}
+
+// newDiskHandler returns the handler of a static files route on a disk directory
+func newDiskHandler(ap *AssetPath) fasthttp.RequestHandler {
+	fileServer := &fasthttp.FS{
+		Root:               ap.FileSystemRoot,
+		IndexNames:         ap.Opts.IndexNames,
+		GenerateIndexPages: ap.Opts.GenerateIndexPages,
+		Compress:           ap.Opts.Compress,
+		AcceptByteRange:    ap.Opts.AcceptByteRange,
+		CacheDuration:      ap.Opts.CacheDuration,
+	}
+	if ap.StripSlashes > 0 {
+		fileServer.PathRewrite = fasthttp.NewPathSlashesStripper(ap.StripSlashes)
+	}
+	if ap.Opts.SPAFallback {
+		spa := &fsFileServer{fsys: os.DirFS(ap.FileSystemRoot), opts: ap.Opts, modTime: time.Now()}
+		fileServer.PathNotFound = spa.serveSPAIndex
+	}
+	return fileServer.NewRequestHandler()
+}
+
+// See if we match a file handler - First match is the one we use
+func (r *Rox) getFSHandler(ctx *fasthttp.RequestCtx) (handler fasthttp.RequestHandler, ok bool) {
+	path := ctx.Path()
+	for _, astPath := range r.Options.assetPaths {
+		if bytes.HasPrefix(path, astPath.Prefix) && !astPath.excludes(path) {
+			return astPath.handler, true
+		}
+	}
+	return
+}
+
+// excludes reports whether the path is left to the router by Opts.Exclude
+func (ap *AssetPath) excludes(path []byte) bool {
+	for _, prefix := range ap.exclude {
+		if bytes.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,449 @@
+package rox
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestStaticOpts(t *testing.T) {
+	r := New()
+	r.AddStaticFilesRoute("/css/", "dist_test/css", 1)
+	r.AddStaticFilesRoute("/assets/", "dist_test", 1, StaticOpts{AcceptByteRange: false})
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+
+	runStaticTests(t, r, fsvr, []string{""}, []staticTest{
+		{name: "Default options serve byte ranges", path: "/css/sample.css",
+			headers:     map[string]string{"Range": "bytes=0-9"},
+			wantStatus:  fasthttp.StatusPartialContent,
+			wantHeaders: map[string]string{"Content-Range": "bytes 0-9/", "Accept-Ranges": "bytes"},
+		},
+		{name: "Default options list directories", path: "/css/",
+			wantStatus: fasthttp.StatusOK, wantBody: "sample.css",
+		},
+		{name: "Byte ranges disabled", path: "/assets/css/sample.css",
+			headers:    map[string]string{"Range": "bytes=0-9"},
+			wantStatus: fasthttp.StatusOK, wantBody: "background-color",
+			noHeaders: []string{"Content-Range"},
+		},
+		{name: "Directory listing disabled", path: "/assets/css/",
+			wantStatus: fasthttp.StatusForbidden, noBody: "sample.css",
+		},
+	})
+}
+
+//go:embed dist_test
+var distTest embed.FS
+
+// TestStaticFS checks that files served from an fs.FS behave as those served from disk
+func TestStaticFS(t *testing.T) {
+	assets, err := fs.Sub(distTest, "dist_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := StaticOpts{AcceptByteRange: true, IndexNames: []string{"index.html"}}
+	r := New()
+	r.AddStaticFilesRoute("/disk/", "dist_test", 1, opts)
+	r.AddStaticFS("/embed/", assets, 1, opts)
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+
+	runStaticTests(t, r, fsvr, []string{"/disk/", "/embed/"}, []staticTest{
+		{name: "File", path: "css/sample.css",
+			wantStatus: fasthttp.StatusOK, wantBody: "background-color",
+			wantHeaders: map[string]string{"Content-Type": "text/css", "Last-Modified": "", "Accept-Ranges": "bytes"},
+		},
+		{name: "Image", path: "images/dove.jpg",
+			wantStatus: fasthttp.StatusOK, wantHeaders: map[string]string{"Content-Type": "image/jpeg"},
+		},
+		{name: "Index file", path: "",
+			wantStatus: fasthttp.StatusOK, wantBody: "Rox test app",
+			wantHeaders: map[string]string{"Content-Type": "text/html"},
+		},
+		{name: "Directory without index", path: "css/",
+			wantStatus: fasthttp.StatusForbidden,
+		},
+		{name: "Missing file", path: "css/missing.css",
+			wantStatus: fasthttp.StatusNotFound,
+		},
+		{name: "Range", path: "css/sample.css", headers: map[string]string{"Range": "bytes=0-3"},
+			wantStatus:  fasthttp.StatusPartialContent,
+			wantHeaders: map[string]string{"Content-Range": "bytes 0-3/", "Content-Length": "4"},
+		},
+		{name: "Unsatisfiable range", path: "css/sample.css", headers: map[string]string{"Range": "bytes=100000-"},
+			wantStatus: fasthttp.StatusRequestedRangeNotSatisfiable,
+		},
+		{name: "Not modified", path: "css/sample.css",
+			headers:    map[string]string{"If-Modified-Since": "Fri, 01 Jan 2100 00:00:00 GMT"},
+			wantStatus: fasthttp.StatusNotModified, noBody: "background-color",
+		},
+		{name: "Modified", path: "css/sample.css",
+			headers:    map[string]string{"If-Modified-Since": "Thu, 01 Jan 1970 00:00:00 GMT"},
+			wantStatus: fasthttp.StatusOK, wantBody: "background-color",
+		},
+		{name: "HEAD", method: "HEAD", path: "css/sample.css",
+			wantStatus: fasthttp.StatusOK, noBody: "background-color",
+			wantHeaders: map[string]string{"Content-Type": "text/css"},
+		},
+		{name: "Dot segments stay under the root", path: "../rox.go",
+			wantStatus: fasthttp.StatusNotFound,
+		},
+	})
+}
+
+func TestStaticFSStripSlashes(t *testing.T) {
+	assets, err := fs.Sub(distTest, "dist_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := fstest.MapFS{
+		"a b.txt":   {Data: []byte("a b")},
+		"50%.txt":   {Data: []byte("50%")},
+		"why?.txt":  {Data: []byte("why")},
+		"sub/x.txt": {Data: []byte("x")},
+	}
+	r := New()
+	r.AddStaticFS("/v1/app/", assets, 2)
+	r.AddStaticFS("/dist_test/", distTest, 0)
+	r.AddStaticFS("/list/", listed, 1)
+	shop := New()
+	shop.AddStaticFS("/embed/", assets, 1)
+	r.Mount("/shop", shop)
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+
+	for _, target := range []string{"/v1/app/css/sample.css", "/dist_test/css/sample.css", "/shop/embed/css/sample.css"} {
+		resp := readResponse(t, r, fsvr, httptest.NewRequest("GET", target, nil))
+		if resp.StatusCode() != fasthttp.StatusOK || !strings.Contains(string(resp.Body()), "background-color") {
+			t.Errorf("GET %s: status %d, body %q", target, resp.StatusCode(), resp.Body())
+		}
+	}
+
+	resp := readResponse(t, r, fsvr, httptest.NewRequest("GET", "/list/", nil))
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("GET /list/: status %d", resp.StatusCode())
+	}
+	for _, href := range []string{`href="/list/50%25.txt"`, `href="/list/a%20b.txt"`, `href="/list/sub/"`, `href="/list/why%3F.txt"`} {
+		if !strings.Contains(string(resp.Body()), href) {
+			t.Errorf("listing %s should contain %s", resp.Body(), href)
+		}
+	}
+	resp = readResponse(t, r, fsvr, httptest.NewRequest("GET", "/list/why%3F.txt", nil))
+	if resp.StatusCode() != fasthttp.StatusOK || string(resp.Body()) != "why" {
+		t.Errorf("GET /list/why%%3F.txt: status %d, body %q", resp.StatusCode(), resp.Body())
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	assets, err := fs.Sub(distTest, "dist_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.AddStaticFilesRoute("/disk/", "dist_test", 1,
+		StaticOpts{IndexNames: []string{"index.html"}, SPAFallback: true, Exclude: []string{"/disk/api/"}})
+	r.AddStaticFS("/embed/", assets, 1,
+		StaticOpts{IndexNames: []string{"index.html"}, SPAFallback: true, Exclude: []string{"/embed/api/"}})
+	for _, prefix := range []string{"/disk", "/embed"} {
+		r.Get(prefix+"/api/users", func(ctx *fasthttp.RequestCtx, params Params) {
+			_, _ = ctx.WriteString("users list")
+		})
+	}
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+
+	runStaticTests(t, r, fsvr, []string{"/disk/", "/embed/"}, []staticTest{
+		{name: "Deep link", path: "orders/42/edit",
+			wantStatus: fasthttp.StatusOK, wantBody: "Rox test app",
+			wantHeaders: map[string]string{"Content-Type": "text/html"},
+		},
+		{name: "Root", path: "",
+			wantStatus: fasthttp.StatusOK, wantBody: "Rox test app",
+		},
+		{name: "Real file", path: "css/sample.css",
+			wantStatus: fasthttp.StatusOK, wantBody: "background-color",
+			wantHeaders: map[string]string{"Content-Type": "text/css"},
+		},
+		{name: "Excluded route", path: "api/users",
+			wantStatus: fasthttp.StatusOK, wantBody: "users list",
+		},
+		{name: "Excluded unknown path", path: "api/nothing",
+			wantStatus: fasthttp.StatusNotFound, noBody: "Rox test app",
+		},
+		{name: "Deep link POST", method: "POST", path: "orders/42",
+			wantStatus: fasthttp.StatusNotFound, noBody: "Rox test app",
+		},
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	css, err := os.ReadFile("dist_test/css/sample.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(css)
+	hash := hex.EncodeToString(sum[:])[:8]
+
+	manifestDir := t.TempDir()
+	if err = os.WriteFile(filepath.Join(manifestDir, "app.0123abcd.js"), []byte("console.log('built')"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(manifestDir, "manifest.json"), []byte(`{"app.js": "app.0123abcd.js"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assets, err := fs.Sub(distTest, "dist_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.AddStaticFilesRoute("/css/", "dist_test/css", 1, StaticOpts{Fingerprint: true})
+	r.AddStaticFS("/embed/", assets, 1, StaticOpts{Fingerprint: true})
+	r.AddStaticFilesRoute("/js/", manifestDir, 1, StaticOpts{Manifest: "manifest.json"})
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+
+	assetTests := []struct {
+		path, want string
+	}{
+		{"/css/sample.css", "/css/sample." + hash + ".css"},
+		{"/embed/css/sample.css", "/embed/css/sample." + hash + ".css"},
+		{"/js/app.js", "/js/app.0123abcd.js"},
+		{"/css/unknown.css", "/css/unknown.css"},
+	}
+	for _, tt := range assetTests {
+		if got := r.Asset(tt.path); got != tt.want {
+			t.Errorf("Asset(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	runStaticTests(t, r, fsvr, []string{""}, []staticTest{
+		{name: "Hashed URL", path: "/css/sample." + hash + ".css",
+			wantStatus: fasthttp.StatusOK, wantBody: "background-color",
+			wantHeaders: map[string]string{"Content-Type": "text/css", "Cache-Control": CacheControlImmutable},
+		},
+		{name: "Original URL", path: "/css/sample.css",
+			wantStatus: fasthttp.StatusOK, wantBody: "background-color",
+			noHeaders: []string{"Cache-Control"},
+		},
+		{name: "Stale hash", path: "/css/sample.00000000.css",
+			wantStatus: fasthttp.StatusNotFound,
+		},
+		{name: "Hashed URL from fs.FS", path: "/embed/css/sample." + hash + ".css",
+			wantStatus: fasthttp.StatusOK, wantBody: "background-color",
+			wantHeaders: map[string]string{"Cache-Control": CacheControlImmutable},
+		},
+		{name: "Hashed URL from manifest", path: "/js/app.0123abcd.js",
+			wantStatus: fasthttp.StatusOK, wantBody: "console.log('built')",
+			wantHeaders: map[string]string{"Cache-Control": CacheControlImmutable},
+		},
+	})
+}
+
+func TestFingerprintChangedFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.css")
+	if err := os.WriteFile(name, []byte("body { color: red; }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.AddStaticFilesRoute("/css/", dir, 1, StaticOpts{Fingerprint: true})
+	r.AddStaticFilesRoute("/missing/", filepath.Join(dir, "missing"), 1, StaticOpts{Fingerprint: true})
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()} // a route failing to fingerprint is served as is
+	hashedURL := r.Asset("/css/app.css")
+
+	get := func(target string) *fasthttp.Response {
+		return readResponse(t, r, fsvr, httptest.NewRequest("GET", target, nil))
+	}
+	if resp := get(hashedURL); resp.StatusCode() != fasthttp.StatusOK || string(resp.Body()) != "body { color: red; }" {
+		t.Fatalf("GET %s: status %d, body %q", hashedURL, resp.StatusCode(), resp.Body())
+	}
+
+	// Once edited, the file no longer has the hash of the URL
+	if err := os.WriteFile(name, []byte("body { color: blue; }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(name, future, future)
+	if resp := get(hashedURL); resp.StatusCode() != fasthttp.StatusNotFound {
+		t.Errorf("GET %s of an edited file: status %d, want 404", hashedURL, resp.StatusCode())
+	}
+	if resp := get("/missing/app.css"); resp.StatusCode() != fasthttp.StatusNotFound {
+		t.Errorf("GET /missing/app.css: status %d, want 404", resp.StatusCode())
+	}
+}
+
+func TestPrecompressed(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.js":       "console.log('raw')",
+		"app.js.br":    "BROTLI-CONTENT",
+		"app.js.gz":    "GZIP-CONTENT",
+		"style.css":    "body { background-color: white; }",
+		"style.css.gz": "GZIP-CSS",
+		"plain.txt":    strings.Repeat("plain text ", 100),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := New()
+	r.AddStaticFilesRoute("/disk/", dir, 1, StaticOpts{Precompressed: true})
+	r.AddStaticFS("/fs/", os.DirFS(dir), 1, StaticOpts{Precompressed: true})
+	r.AddStaticFS("/compress/", os.DirFS(dir), 1, StaticOpts{Precompressed: true, Compress: true})
+	fsvr := &fasthttp.Server{Handler: r.PrepareServer()}
+
+	acceptEncoding := func(encodings string) map[string]string {
+		return map[string]string{"Accept-Encoding": encodings}
+	}
+	runStaticTests(t, r, fsvr, []string{"/disk/", "/fs/"}, []staticTest{
+		{name: "Brotli preferred", path: "app.js", headers: acceptEncoding("gzip, deflate, br"),
+			wantStatus: fasthttp.StatusOK, wantBody: "BROTLI-CONTENT",
+			wantHeaders: map[string]string{"Content-Encoding": "br", "Vary": "Accept-Encoding", "Content-Type": "text/javascript"},
+		},
+		{name: "Gzip", path: "app.js", headers: acceptEncoding("gzip"),
+			wantStatus: fasthttp.StatusOK, wantBody: "GZIP-CONTENT",
+			wantHeaders: map[string]string{"Content-Encoding": "gzip", "Vary": "Accept-Encoding"},
+		},
+		{name: "Brotli refused", path: "app.js", headers: acceptEncoding("br;q=0, *"),
+			wantStatus: fasthttp.StatusOK, wantBody: "GZIP-CONTENT",
+			wantHeaders: map[string]string{"Content-Encoding": "gzip"},
+		},
+		{name: "No Accept-Encoding", path: "app.js",
+			wantStatus: fasthttp.StatusOK, wantBody: "console.log('raw')",
+			wantHeaders: map[string]string{"Vary": "Accept-Encoding"}, noHeaders: []string{"Content-Encoding"},
+		},
+		{name: "Missing sibling", path: "style.css", headers: acceptEncoding("br"),
+			wantStatus: fasthttp.StatusOK, wantBody: "background-color",
+			wantHeaders: map[string]string{"Content-Type": "text/css"}, noHeaders: []string{"Content-Encoding"},
+		},
+		{name: "Available sibling", path: "style.css", headers: acceptEncoding("br, gzip"),
+			wantStatus: fasthttp.StatusOK, wantBody: "GZIP-CSS",
+			wantHeaders: map[string]string{"Content-Encoding": "gzip", "Content-Type": "text/css"},
+		},
+		{name: "No siblings", path: "plain.txt", headers: acceptEncoding("gzip"),
+			wantStatus: fasthttp.StatusOK, wantBody: "plain text", noHeaders: []string{"Content-Encoding"},
+		},
+	})
+
+	// Without a sibling, the file is compressed on the fly
+	runStaticTests(t, r, fsvr, []string{"/compress/"}, []staticTest{
+		{name: "Compressed on the fly", path: "plain.txt", headers: acceptEncoding("gzip"),
+			wantStatus: fasthttp.StatusOK, noBody: "plain text",
+			wantHeaders: map[string]string{"Content-Encoding": "gzip"},
+		},
+	})
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+		want           bool
+	}{
+		{"gzip, deflate, br", "br", true},
+		{"gzip", "br", false},
+		{"", "gzip", false},
+		{"br;q=0, gzip", "br", false},
+		{"BR; q=0.5", "br", true},
+		{"*", "gzip", true},
+		{"*, gzip;q=0", "gzip", false},
+		{"*;q=0, br", "br", true},
+	}
+	for _, tt := range tests {
+		if got := acceptsEncoding([]byte(tt.acceptEncoding), tt.encoding); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, want %v", tt.acceptEncoding, tt.encoding, got, tt.want)
+		}
+	}
+}
+
+// staticTest is a request to a static files route and its expected response
+type staticTest struct {
+	name        string
+	method      string            // GET if empty
+	path        string            // under each prefix
+	headers     map[string]string // request headers
+	wantStatus  int
+	wantHeaders map[string]string // response headers, by the start of their value
+	noHeaders   []string          // response headers which must be absent
+	wantBody    string            // part of the body, if set
+	noBody      string            // must not be part of the body, if set
+}
+
+// runStaticTests runs each test under each prefix,
+// checking the status, headers and body of the parsed response
+func runStaticTests(t *testing.T, r *Rox, fsvr *fasthttp.Server, prefixes []string, tests []staticTest) {
+	t.Helper()
+	for _, tt := range tests {
+		for _, prefix := range prefixes {
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			t.Run(strings.TrimSpace(tt.name+" "+prefix), func(t *testing.T) {
+				req := httptest.NewRequest(method, prefix+tt.path, nil)
+				for k, v := range tt.headers {
+					req.Header.Set(k, v)
+				}
+				resp := readResponse(t, r, fsvr, req)
+				if resp.StatusCode() != tt.wantStatus {
+					t.Errorf("%s %s: status %d, want %d", method, prefix+tt.path, resp.StatusCode(), tt.wantStatus)
+				}
+				for k, want := range tt.wantHeaders {
+					if got := resp.Header.Peek(k); len(got) == 0 || !strings.HasPrefix(string(got), want) {
+						t.Errorf("%s %s: header %s %q, want %q", method, prefix+tt.path, k, got, want)
+					}
+				}
+				for _, k := range tt.noHeaders {
+					if got := resp.Header.Peek(k); len(got) > 0 {
+						t.Errorf("%s %s: unexpected header %s %q", method, prefix+tt.path, k, got)
+					}
+				}
+				bd := string(resp.Body())
+				if tt.wantBody != "" && !strings.Contains(bd, tt.wantBody) {
+					t.Errorf("%s %s: body %q, should contain %q", method, prefix+tt.path, bd, tt.wantBody)
+				}
+				if tt.noBody != "" && strings.Contains(bd, tt.noBody) {
+					t.Errorf("%s %s: body %q, should not contain %q", method, prefix+tt.path, bd, tt.noBody)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkStaticFiles serves a file through the static files route handler, built once
+func BenchmarkStaticFiles(b *testing.B) {
+	r := New()
+	r.AddStaticFilesRoute("/css/", "dist_test/css", 1)
+	handler := r.PrepareServer()
+	benchmarkFileHandler(b, handler)
+}
+
+// BenchmarkStaticFilesPerRequestFS serves the file as before, building a new fasthttp.FS per request
+func BenchmarkStaticFilesPerRequestFS(b *testing.B) {
+	benchmarkFileHandler(b, func(ctx *fasthttp.RequestCtx) {
+		fasthttp.FSHandler("dist_test/css", 1)(ctx)
+	})
+}
+
+func benchmarkFileHandler(b *testing.B, handler fasthttp.RequestHandler) {
+	b.ReportAllocs()
+	var ctx fasthttp.RequestCtx
+	for i := 0; i < b.N; i++ {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.SetRequestURI("/css/sample.css")
+		handler(&ctx)
+		if ctx.Response.StatusCode() != fasthttp.StatusOK {
+			b.Fatalf("status %d", ctx.Response.StatusCode())
+		}
+	}
+}
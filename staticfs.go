@@ -0,0 +1,256 @@
+package rox
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// AddStaticFS adds a route to static files served from any fs.FS, eg an embed.FS.
+// Like AddStaticFilesRoute, slashesToStrip slash delimited tokens are removed from the URL
+// and the rest is the file name in fsys, so use fs.Sub to serve a sub-directory.
+// StaticOpts.CacheDuration does not apply, and Compress compresses on the fly
+// Example:
+//
+//	//go:embed dist
+//	var dist embed.FS
+//
+//	assets, _ := fs.Sub(dist, "dist")
+//	r.AddStaticFS("/app/", assets, 1) // /app/js/main.js -> js/main.js
+func (r *Rox) AddStaticFS(prefix string, fsys fs.FS, slashesToStrip int, opts ...StaticOpts) {
+	ap := AssetPath{Prefix: []byte(prefix), StripSlashes: slashesToStrip, Opts: defaultStaticOpts, fsys: fsys}
+	if len(opts) > 0 {
+		ap.Opts = opts[0]
+	}
+	r.Options.assetPaths = append(r.Options.assetPaths, ap)
+}
+
+// fsFileServer serves the files of an fs.FS
+type fsFileServer struct {
+	fsys         fs.FS
+	stripSlashes int
+	opts         StaticOpts
+	modTime      time.Time // for files without a modification time, like those of an embed.FS
+}
+
+// newFSHandler returns the handler of a static files route on an fs.FS
+func newFSHandler(ap *AssetPath) fasthttp.RequestHandler {
+	fsrv := &fsFileServer{fsys: ap.fsys, stripSlashes: ap.StripSlashes, opts: ap.Opts, modTime: time.Now()}
+	if ap.Opts.Compress {
+		return fasthttp.CompressHandlerBrotliLevel(fsrv.serve, fasthttp.CompressBrotliDefaultCompression,
+			fasthttp.CompressDefaultCompression)
+	}
+	return fsrv.serve
+}
+
+func (fsrv *fsFileServer) serve(ctx *fasthttp.RequestCtx) {
+	name := string(ctx.Path())
+	if fsrv.stripSlashes > 0 {
+		name = string(fasthttp.NewPathSlashesStripper(fsrv.stripSlashes)(ctx))
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		writeRouterStatus(ctx, fasthttp.StatusNotFound, "")
+		return
+	}
+
+	f, err := fsrv.fsys.Open(name)
+	if err != nil {
+		if fsrv.opts.SPAFallback {
+			fsrv.serveSPAIndex(ctx)
+			return
+		}
+		writeRouterStatus(ctx, fasthttp.StatusNotFound, "")
+		return
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		writeRouterStatus(ctx, fasthttp.StatusNotFound, "")
+		return
+	}
+
+	if fi.IsDir() {
+		_ = f.Close()
+		fsrv.serveDir(ctx, name)
+		return
+	}
+	fsrv.serveFile(ctx, f, fi)
+}
+
+// serveDir serves the index file of the directory, or else its listing if enabled
+func (fsrv *fsFileServer) serveDir(ctx *fasthttp.RequestCtx, dir string) {
+	for _, index := range fsrv.opts.IndexNames {
+		f, err := fsrv.fsys.Open(path.Join(dir, index))
+		if err != nil {
+			continue
+		}
+		if fi, err := f.Stat(); err == nil && !fi.IsDir() {
+			fsrv.serveFile(ctx, f, fi)
+			return
+		}
+		_ = f.Close()
+	}
+
+	if !fsrv.opts.GenerateIndexPages {
+		writeRouterStatus(ctx, fasthttp.StatusForbidden, "Directory index is forbidden")
+		return
+	}
+	entries, err := fs.ReadDir(fsrv.fsys, dir)
+	if err != nil {
+		writeRouterStatus(ctx, fasthttp.StatusInternalServerError, "")
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	base := string(ctx.Path())
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	escapedBase := (&url.URL{Path: base}).EscapedPath()
+	var sb strings.Builder
+	sb.WriteString("<html><head><title>" + html.EscapeString(base) + "</title></head><body><ul>")
+	for _, e := range entries {
+		entryName := e.Name()
+		if e.IsDir() {
+			entryName += "/"
+		}
+		href := url.PathEscape(e.Name())
+		if e.IsDir() {
+			href += "/"
+		}
+		sb.WriteString(`<li><a href="` + html.EscapeString(escapedBase+href) + `">` + html.EscapeString(entryName) + "</a></li>")
+	}
+	sb.WriteString("</ul></body></html>")
+	ctx.SetContentType("text/html; charset=utf-8")
+	_, _ = ctx.WriteString(sb.String())
+}
+
+// serveSPAIndex serves the index file at the root of the single-page application, see StaticOpts.SPAFallback
+func (fsrv *fsFileServer) serveSPAIndex(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() && !ctx.IsHead() {
+		writeRouterStatus(ctx, fasthttp.StatusNotFound, "")
+		return
+	}
+	index := "index.html"
+	if len(fsrv.opts.IndexNames) > 0 {
+		index = fsrv.opts.IndexNames[0]
+	}
+	f, err := fsrv.fsys.Open(index)
+	if err != nil {
+		writeRouterStatus(ctx, fasthttp.StatusNotFound, "")
+		return
+	}
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		_ = f.Close()
+		writeRouterStatus(ctx, fasthttp.StatusNotFound, "")
+		return
+	}
+	ctx.Request.Header.Del("Range") // the client asked for a range of another file
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	fsrv.serveFile(ctx, f, fi)
+}
+
+// serveFile serves the file, handling If-Modified-Since and Range requests. It closes f
+func (fsrv *fsFileServer) serveFile(ctx *fasthttp.RequestCtx, f fs.File, fi fs.FileInfo) {
+	fsrv.serveContent(ctx, f, fi, fi.Name(), "")
+}
+
+// serveContent serves the file as the content of the file name with the Content-Encoding, if any.
+// Only unencoded content is served by ranges. It closes f
+func (fsrv *fsFileServer) serveContent(ctx *fasthttp.RequestCtx, f fs.File, fi fs.FileInfo, name, encoding string) {
+	modTime := fi.ModTime()
+	if modTime.IsZero() {
+		modTime = fsrv.modTime
+	}
+	if !ctx.IfModifiedSince(modTime) {
+		_ = f.Close()
+		ctx.NotModified()
+		return
+	}
+
+	seeker, canSeek := f.(io.Seeker)
+	contentType := mime.TypeByExtension(path.Ext(name))
+	var body io.Reader = f
+	if contentType == "" && encoding == "" { // sniff the content
+		buf := make([]byte, 512)
+		n, _ := io.ReadFull(f, buf)
+		contentType = http.DetectContentType(buf[:n])
+		if canSeek {
+			_, err := seeker.Seek(0, io.SeekStart)
+			canSeek = err == nil
+		}
+		if !canSeek {
+			body = io.MultiReader(bytes.NewReader(buf[:n]), f)
+		}
+	}
+
+	h := &ctx.Response.Header
+	if contentType != "" {
+		h.SetContentType(contentType)
+	}
+	h.SetLastModified(modTime)
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+	}
+	if fsrv.opts.Compress || fsrv.opts.Precompressed {
+		h.Set("Vary", "Accept-Encoding")
+	}
+
+	size := int(fi.Size())
+	start, end := 0, size-1
+	if fsrv.opts.AcceptByteRange && encoding == "" {
+		h.Set("Accept-Ranges", "bytes")
+		byteRange := ctx.Request.Header.Peek("Range")
+		// Ranges are ignored when the response may get compressed
+		if len(byteRange) > 0 && canSeek && !fsrv.compressible(ctx) {
+			var err error
+			if start, end, err = fasthttp.ParseByteRange(byteRange, size); err != nil {
+				_ = f.Close()
+				h.Set("Content-Range", "bytes */"+strconv.Itoa(size))
+				writeRouterStatus(ctx, fasthttp.StatusRequestedRangeNotSatisfiable, "")
+				return
+			}
+			if _, err = seeker.Seek(int64(start), io.SeekStart); err != nil {
+				_ = f.Close()
+				writeRouterStatus(ctx, fasthttp.StatusInternalServerError, "")
+				return
+			}
+			h.SetContentRange(start, end, size)
+			ctx.SetStatusCode(fasthttp.StatusPartialContent)
+		}
+	}
+
+	length := end - start + 1
+	ctx.SetBodyStream(readCloser{io.LimitReader(body, int64(length)), f}, length)
+}
+
+// compressible reports whether the response to the request may get compressed
+func (fsrv *fsFileServer) compressible(ctx *fasthttp.RequestCtx) bool {
+	if !fsrv.opts.Compress {
+		return false
+	}
+	h := &ctx.Request.Header
+	return h.HasAcceptEncoding("br") || h.HasAcceptEncoding("gzip") || h.HasAcceptEncoding("deflate")
+}
+
+// readCloser reads from a reader and closes a closer, eg a part of a file and the file
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -36,9 +36,7 @@ func TestRunner(r *Rox, s *fasthttp.Server, req *http.Request) (resp *fasthttp.R
 
 	// Prepare Server, or we'll just work with the one passed in
 	if s == nil {
-		s = &fasthttp.Server{
-			Handler: r.PrepareServer(),
-		}
+		s = r.newServer(r.PrepareServer())
 	}
 
 	if err := s.ServeConn(cw); err != nil {
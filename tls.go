@@ -0,0 +1,261 @@
+package rox
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Cert is a TLS certificate and its private key, from PEM files or PEM data
+type Cert struct {
+	CertFile string
+	KeyFile  string
+	CertData []byte
+	KeyData  []byte
+}
+
+// certStore holds the loaded certificates and selects one per TLS handshake
+type certStore struct {
+	certs []Cert
+
+	mu       sync.RWMutex
+	loaded   []*tls.Certificate          // in the order of certs, the first is the default
+	byName   map[string]*tls.Certificate // lowercase DNS name, incl. wildcards, to certificate
+	modTimes map[string]time.Time        // cert and key file modification times at the last load attempt
+}
+
+// certs returns the certificates configured in TLSOpts: the main one first, then the SNI ones
+func (o TLSOpts) certs() (certs []Cert) {
+	if o.CertFile != "" || len(o.CertData) > 0 {
+		certs = append(certs, Cert{CertFile: o.CertFile, KeyFile: o.KeyFile, CertData: o.CertData, KeyData: o.KeyData})
+	}
+	return append(certs, o.Certs...)
+}
+
+// newCertStore loads the certificates
+func newCertStore(certs []Cert) (*certStore, error) {
+	cs := &certStore{certs: certs}
+	if err := cs.reload(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// reload loads all the certificates again. On error the previous ones are kept,
+// until the files change again
+func (cs *certStore) reload() error {
+	loaded := make([]*tls.Certificate, 0, len(cs.certs))
+	byName := make(map[string]*tls.Certificate)
+	modTimes := make(map[string]time.Time)
+
+	for _, c := range cs.certs {
+		for _, file := range []string{c.CertFile, c.KeyFile} {
+			if file == "" {
+				continue
+			}
+			if fi, err := os.Stat(file); err == nil {
+				modTimes[file] = fi.ModTime()
+			}
+		}
+	}
+	cs.mu.Lock()
+	cs.modTimes = modTimes
+	cs.mu.Unlock()
+
+	for _, c := range cs.certs {
+		var cert tls.Certificate
+		var err error
+		if c.CertFile != "" {
+			cert, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		} else {
+			cert, err = tls.X509KeyPair(c.CertData, c.KeyData)
+		}
+		if err != nil {
+			return fmt.Errorf("rox: cannot load certificate %q - %w", c.CertFile, err)
+		}
+		if cert.Leaf == nil {
+			if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return fmt.Errorf("rox: cannot parse certificate %q - %w", c.CertFile, err)
+			}
+		}
+		loaded = append(loaded, &cert)
+
+		names := cert.Leaf.DNSNames
+		if len(names) == 0 && cert.Leaf.Subject.CommonName != "" {
+			names = []string{cert.Leaf.Subject.CommonName}
+		}
+		for _, name := range names {
+			name = strings.ToLower(name)
+			if _, ok := byName[name]; !ok { // the first certificate for a name wins
+				byName[name] = &cert
+			}
+		}
+	}
+
+	cs.mu.Lock()
+	cs.loaded, cs.byName = loaded, byName
+	cs.mu.Unlock()
+	return nil
+}
+
+// changed reports whether any certificate or key file was modified since the last load
+func (cs *certStore) changed() bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	for file, modTime := range cs.modTimes {
+		if fi, err := os.Stat(file); err == nil && !fi.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// getCertificate selects the certificate for the SNI server name:
+// an exact match first, then a wildcard match, else the default certificate
+func (cs *certStore) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	if len(cs.loaded) == 0 {
+		return nil, errors.New("rox: no certificate loaded")
+	}
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if cert, ok := cs.byName[name]; ok {
+		return cert, nil
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if cert, ok := cs.byName["*"+name[i:]]; ok {
+			return cert, nil
+		}
+	}
+	return cs.loaded[0], nil
+}
+
+// watch reloads the certificates when their files change, checked every interval,
+// and on SIGHUP if onSIGHUP, until ctx is done
+func (cs *certStore) watch(ctx context.Context, interval time.Duration, onSIGHUP bool) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	var hup chan os.Signal
+	if onSIGHUP {
+		hup = make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			if !cs.changed() {
+				continue
+			}
+		case <-hup:
+		}
+		if err := cs.reload(); err != nil {
+			log.Println("TLS certificate reload error:", err)
+		}
+	}
+}
+
+// ClientAuthMode is the policy for TLS client certificates
+type ClientAuthMode int
+
+const (
+	ClientAuthNone          ClientAuthMode = iota // no client certificate is requested
+	ClientAuthRequest                             // a client certificate is requested but not verified
+	ClientAuthRequire                             // a client certificate verified against the client CAs is required
+	ClientAuthVerifyIfGiven                       // a client certificate is optional, but verified if given
+)
+
+// tlsClientAuth returns the crypto/tls equivalent of the mode
+func (m ClientAuthMode) tlsClientAuth() tls.ClientAuthType {
+	switch m {
+	case ClientAuthRequest:
+		return tls.RequestClientCert
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert
+	case ClientAuthVerifyIfGiven:
+		return tls.VerifyClientCertIfGiven
+	default:
+		return tls.NoClientCert
+	}
+}
+
+// clientCAs loads the client CA bundle from Options.TLS, or returns nil if none is configured
+func (o TLSOpts) clientCAs() (*x509.CertPool, error) {
+	data := o.ClientCAData
+	if o.ClientCAFile != "" {
+		var err error
+		if data, err = os.ReadFile(o.ClientCAFile); err != nil {
+			return nil, fmt.Errorf("rox: cannot read client CA file - %w", err)
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("rox: no certificate found in client CA bundle %q", o.ClientCAFile)
+	}
+	return pool, nil
+}
+
+// tlsConfig builds the server TLS config from Options.TLS,
+// or returns nil when TLS is not used or no certificate is configured
+func (r *Rox) tlsConfig() (*tls.Config, error) {
+	tlsOpts := r.Options.TLS
+	certs := tlsOpts.certs()
+	if !tlsOpts.UseTLS || len(certs) == 0 {
+		return nil, nil
+	}
+
+	clientCAs, err := tlsOpts.clientCAs()
+	if err != nil {
+		return nil, err
+	}
+	if clientCAs == nil && (tlsOpts.ClientAuth == ClientAuthRequire || tlsOpts.ClientAuth == ClientAuthVerifyIfGiven) {
+		return nil, errors.New("rox: client certificate verification needs TLSOpts.ClientCAFile or ClientCAData")
+	}
+
+	cs, err := newCertStore(certs)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.certs = cs
+	r.mu.Unlock()
+
+	return &tls.Config{
+		GetCertificate: cs.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+		ClientAuth:     tlsOpts.ClientAuth.tlsClientAuth(),
+		ClientCAs:      clientCAs,
+	}, nil
+}
+
+// ReloadCertificates loads the TLS certificate files again, eg after a renewal.
+// On error the certificates in use are kept
+func (r *Rox) ReloadCertificates() error {
+	r.mu.Lock()
+	cs := r.certs
+	r.mu.Unlock()
+	if cs == nil {
+		return errors.New("rox: TLS is not serving")
+	}
+	return cs.reload()
+}
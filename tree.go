@@ -8,6 +8,8 @@ import (
 	"regexp"
 	"sort"
 	"sync"
+
+	"github.com/valyala/fasthttp"
 )
 
 const (
@@ -21,8 +23,11 @@ const (
 
 // route stores the route entry in the router
 type route struct {
-	p Pattern
-	h Handler
+	p     Pattern
+	h     Handler
+	mws   []MiddleWare // route specific middlewares
+	owner *Rox         // router the route was registered on
+	chain Handler      // h wrapped by the router and route middlewares
 }
 
 func (rt route) key() string { return rt.p.Key() }
@@ -50,28 +55,56 @@ type tree struct {
 
 	// static pattern is handled separately
 	// Learn from aero (https://github.com/aerogo/aero)
-	static      map[string]Handler
+	static      map[string]*route
 	canBeStatic [2048]bool
 
 	supportVerb bool
 }
 
-func (t *tree) Add(p Pattern, h Handler) {
+// Add adds a route of the owner router with its own middlewares to the tree
+func (t *tree) Add(p Pattern, h Handler, owner *Rox, mws ...MiddleWare) {
+	rt := route{p: p, h: h, mws: mws, owner: owner}
+	rt.chain = rt.ownChain()
 	if len(p.fields) == 0 { // static
 		if t.static == nil {
-			t.static = make(map[string]Handler)
+			t.static = make(map[string]*route)
 		}
-		t.static[p.pattern] = h
+		t.static[p.pattern] = &rt
 		t.canBeStatic[len(p.pattern)] = true
 	} else {
-		t.routes = append(t.routes, route{p, h})
+		t.routes = append(t.routes, rt)
+	}
+}
+
+// Wrap rebuilds the handler chain of every route.
+// The given middlewares are the outermost, followed by the route's own middlewares
+func (t *tree) Wrap(mws []MiddleWare) {
+	for i := range t.routes {
+		t.routes[i].wrap(mws)
+	}
+	for _, rt := range t.static {
+		rt.wrap(mws)
+	}
+}
+
+func (rt *route) wrap(mws []MiddleWare) {
+	rt.chain = chain(rt.ownChain(), mws)
+}
+
+// ownChain returns h wrapped by the route's own middlewares.
+// It records the owner of the route on the request, for its error and panic handlers
+func (rt *route) ownChain() Handler {
+	h, owner := chain(rt.h, rt.mws), rt.owner
+	return func(ctx *fasthttp.RequestCtx, params Params) {
+		ctx.SetUserValue(routerKey{}, owner)
+		h(ctx, params)
 	}
 }
 
 func (t *tree) StaticMatch(path string) Handler {
 	if t.canBeStatic[len(path)] {
-		if h, found := t.static[path]; found {
-			return h
+		if rt, found := t.static[path]; found {
+			return rt.chain
 		}
 	}
 	return nil
@@ -179,7 +212,7 @@ OUTER:
 		i := -t.base[endState] - 1
 		params.path = path
 		params.names = t.routes[i].p.fields
-		h = t.routes[i].h
+		h = t.routes[i].chain
 		pattern = t.routes[i].p.pattern
 	}
 	return
@@ -210,8 +243,8 @@ func (t *tree) matchReParam(state, sc int, segment string) int {
 // match returns the handler and path parameters that matches the given path.
 func (t *tree) match(path string, params *Params) (h Handler, pattern string) {
 	if t.canBeStatic[len(path)] {
-		if handler, found := t.static[path]; found {
-			return handler, pattern
+		if rt, found := t.static[path]; found {
+			return rt.chain, pattern
 		}
 	}
 	return t.PatternMatch(path, params)
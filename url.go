@@ -0,0 +1,154 @@
+package rox
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RouteRef refers to a registered route, so that it can be given a name
+type RouteRef struct {
+	rox     *Rox
+	pattern string
+}
+
+// Name names the route so that its URL can be built with Rox.URL
+// Example:
+//
+//	r.Get("/student/:name/class/:className", classHandler).Name("class")
+//	u, err := r.URL("class", "name", "john", "className", "Math") // -> /student/john/class/Math
+func (rr *RouteRef) Name(name string) *RouteRef {
+	if _, exists := rr.rox.names[name]; exists {
+		panic(fmt.Errorf("router: duplicate route name - %q", name))
+	}
+	tmpl, err := newURLTemplate(rr.pattern)
+	if err != nil {
+		panic(fmt.Errorf("router: cannot name route %q - %w", name, err))
+	}
+	if rr.rox.names == nil {
+		rr.rox.names = make(map[string]*urlTemplate)
+	}
+	rr.rox.names[name] = tmpl
+	return rr
+}
+
+// URL builds the path of the route registered with the given name.
+// params are name, value pairs of the path parameters.
+// Values are percent-encoded and must satisfy the regular expressions of the pattern.
+// Routes named in mounted sub-routers are found with their mount prefix
+func (r *Rox) URL(name string, params ...string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("url: odd number of params for route %q", name)
+	}
+	prefix, tmpl := r.namedRoute(name)
+	if tmpl == nil {
+		return "", fmt.Errorf("url: unknown route name %q", name)
+	}
+	u, err := tmpl.build(params)
+	if err != nil {
+		return "", fmt.Errorf("url: route %q - %w", name, err)
+	}
+	return joinPath(prefix, u), nil
+}
+
+// namedRoute finds a named route in this router or its mounted sub-routers
+func (r *Rox) namedRoute(name string) (prefix string, tmpl *urlTemplate) {
+	if tmpl = r.names[name]; tmpl != nil {
+		return
+	}
+	for _, m := range r.mounts {
+		if subPrefix, t := m.sub.namedRoute(name); t != nil {
+			return m.prefix + subPrefix, t
+		}
+	}
+	return
+}
+
+// urlTemplate is a pattern prepared for building URLs
+type urlTemplate struct {
+	segments []urlSegment
+}
+
+type urlSegment struct {
+	literal  string         // static segment
+	field    string         // parameter name
+	re       *regexp.Regexp // parameter constraint
+	wildcard bool           // '*' parameter, which may span several segments
+}
+
+func newURLTemplate(pattern string) (*urlTemplate, error) {
+	tmpl := &urlTemplate{}
+	for _, seg := range strings.Split(strings.TrimPrefix(pattern, "/"), "/") {
+		var us urlSegment
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			us.field = seg[1:]
+			reSep, typeSep := strings.IndexByte(us.field, '='), strings.IndexByte(us.field, '|')
+			if typeSep >= 0 && (reSep < 0 || typeSep < reSep) { // typed parameter
+				var ok bool
+				if us.field, ok = typedParamRegexp(us.field[:typeSep], us.field[typeSep+1:]); !ok {
+					return nil, fmt.Errorf("pattern has unknown parameter type - %q", pattern)
+				}
+			}
+			if reSep = strings.IndexByte(us.field, '='); reSep >= 0 {
+				re, err := regexp.Compile(us.field[reSep+1:])
+				if err != nil {
+					return nil, err
+				}
+				us.field, us.re = us.field[:reSep], re
+			}
+		case strings.HasPrefix(seg, "*"):
+			us.field, us.wildcard = seg[1:], true
+		default:
+			us.literal = seg
+			tmpl.segments = append(tmpl.segments, us)
+			continue
+		}
+		if us.field == "" {
+			return nil, fmt.Errorf("pattern has an anonymous parameter - %q", pattern)
+		}
+		tmpl.segments = append(tmpl.segments, us)
+	}
+	return tmpl, nil
+}
+
+func (t *urlTemplate) build(params []string) (string, error) {
+	var sb strings.Builder
+	for _, seg := range t.segments {
+		sb.WriteByte('/')
+		if seg.field == "" {
+			sb.WriteString(seg.literal)
+			continue
+		}
+
+		value, found := "", false
+		for i := 0; i < len(params); i += 2 {
+			if params[i] == seg.field {
+				value, found = params[i+1], true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("missing param %q", seg.field)
+		}
+
+		if seg.wildcard {
+			parts := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for i, part := range parts {
+				parts[i] = url.PathEscape(part)
+			}
+			sb.WriteString(strings.Join(parts, "/"))
+			continue
+		}
+
+		if value == "" {
+			return "", fmt.Errorf("empty param %q", seg.field)
+		}
+		if seg.re != nil && !seg.re.MatchString(value) {
+			return "", fmt.Errorf("param %q value %q does not match %q", seg.field, value, seg.re)
+		}
+		sb.WriteString(url.PathEscape(value))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,277 @@
+package rox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ValidationError collects the errors of all the invalid fields
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return "validate: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the fields of the struct v (or pointer to struct) against their validate tags.
+// Rules are separated by commas:
+//
+//	required	the field must not be the zero value (nor a nil pointer)
+//	min=N		minimum value for numbers, minimum length for strings, slices and maps
+//	max=N		maximum value for numbers, maximum length for strings, slices and maps
+//	len=N		exact length of strings, slices and maps
+//	email		the string must be an email address
+//	oneof=a b c	the value must be one of the space separated values
+//
+// Example:
+//
+//	type SignUp struct {
+//		Email string `json:"email" validate:"required,email"`
+//		Age   int    `json:"age" validate:"min=18,max=130"`
+//		Plan  string `json:"plan" validate:"oneof=free pro"`
+//	}
+//
+// Fields are reported by the name of their binding tag, or else by their Go name.
+// Invalid fields are collected into a *ValidationError.
+// Malformed rules are reported as a plain error.
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.New("validate: v must be a struct or a pointer to a struct")
+	}
+	if !rv.CanAddr() { // nested struct checks need addressable values
+		addressable := reflect.New(rv.Type()).Elem()
+		addressable.Set(rv)
+		rv = addressable
+	}
+
+	var errs []FieldError
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func validateStruct(sv reflect.Value, prefix string, errs *[]FieldError) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf, fv := st.Field(i), sv.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := validateStruct(fv, prefix, errs); err != nil {
+				return err
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		name := prefix + fieldName(sf)
+
+		if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+			msg, err := validateField(fv, tag)
+			if err != nil {
+				return fmt.Errorf("validate: field %s - %w", name, err)
+			}
+			if msg != "" {
+				*errs = append(*errs, FieldError{Field: name, Message: msg})
+				continue
+			}
+		}
+
+		// nested structs
+		if fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && !fv.Addr().Type().Implements(textUnmarshalerType) {
+			if err := validateStruct(fv, name+".", errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldName returns the request name of a field
+func fieldName(sf reflect.StructField) string {
+	for _, tag := range [...]string{BindJSON, BindPath, BindQuery, BindForm, BindHeader} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// validateField checks a field against its rules.
+// It returns the message of the first failed rule, or an error for a malformed rule
+func validateField(fv reflect.Value, tag string) (msg string, err error) {
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			if fv.IsZero() {
+				return "is required", nil
+			}
+			continue
+		}
+
+		v := fv
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() { // only required applies to absent values
+				continue
+			}
+			v = v.Elem()
+		}
+
+		switch name {
+		case "min", "max", "len":
+			if msg, err = checkBound(v, name, arg); msg != "" || err != nil {
+				return
+			}
+		case "email":
+			if v.Kind() != reflect.String {
+				return "", fmt.Errorf("email rule on %s", v.Kind())
+			}
+			if s := v.String(); s != "" {
+				if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+					return "must be a valid email address", nil
+				}
+			}
+		case "oneof":
+			s := fmt.Sprint(v.Interface())
+			found := false
+			for _, option := range strings.Fields(arg) {
+				if s == option {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return "must be one of: " + strings.Join(strings.Fields(arg), ", "), nil
+			}
+		default:
+			return "", fmt.Errorf("unknown rule %q", name)
+		}
+	}
+	return "", nil
+}
+
+// checkBound checks a min, max or len rule
+func checkBound(v reflect.Value, rule, arg string) (msg string, err error) {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return "", fmt.Errorf("%s rule has invalid limit %q", rule, arg)
+	}
+
+	var n float64
+	what := "" // the measured property, empty for numbers
+	switch v.Kind() {
+	case reflect.String:
+		n, what = float64(utf8.RuneCountInString(v.String())), "length"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, what = float64(v.Len()), "length"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return "", fmt.Errorf("%s rule on %s", rule, v.Kind())
+	}
+
+	switch {
+	case rule == "min" && n < limit && what == "":
+		return "must be at least " + arg, nil
+	case rule == "min" && n < limit:
+		return "must have a minimum " + what + " of " + arg, nil
+	case rule == "max" && n > limit && what == "":
+		return "must be at most " + arg, nil
+	case rule == "max" && n > limit:
+		return "must have a maximum " + what + " of " + arg, nil
+	case rule == "len" && what == "":
+		return "", fmt.Errorf("len rule on %s", v.Kind())
+	case rule == "len" && n != limit:
+		return "must have a " + what + " of " + arg, nil
+	}
+	return "", nil
+}
+
+// boundKey is the user value key of the request struct bound by Validated
+type boundKey struct{}
+
+// Validated returns a route middleware which binds the request into a new T and validates it.
+// Requests failing to bind (*BindError) or invalid requests (*ValidationError) never reach the handler.
+// Their error goes to the error handler of the route's router, or the routers it is mounted in,
+// so by default they get a 400 or a 422 response listing the field errors. The handler gets the request struct with Bound
+// Example:
+//
+//	r.Post("/signup", func(ctx *fasthttp.RequestCtx, params rox.Params) {
+//		req := rox.Bound[SignUp](ctx)
+//		// ...
+//	}, rox.Validated[SignUp]())
+func Validated[T any]() MiddleWare {
+	return MiddleWare{NextFunc: func(ctx *fasthttp.RequestCtx, params Params, next Handler) {
+		dst := new(T)
+		if err := Bind(ctx, params, dst); err != nil {
+			handleRouteError(ctx, err)
+			return
+		}
+		if err := Validate(dst); err != nil {
+			handleRouteError(ctx, err)
+			return
+		}
+		ctx.SetUserValue(boundKey{}, dst)
+		next(ctx, params)
+	}}
+}
+
+// Bound returns the request struct bound by the Validated middleware, or nil
+func Bound[T any](ctx *fasthttp.RequestCtx) *T {
+	dst, _ := ctx.UserValue(boundKey{}).(*T)
+	return dst
+}
+
+// writeFieldErrors writes a JSON response for the errors of Bind and Validate.
+// A *BindError is a 400, a *ValidationError a 422 and any other error a 500
+func writeFieldErrors(ctx *fasthttp.RequestCtx, err error) {
+	var bindErr *BindError
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &bindErr):
+		writeJSON(ctx, fasthttp.StatusBadRequest, bindErr)
+	case errors.As(err, &validationErr):
+		writeJSON(ctx, fasthttp.StatusUnprocessableEntity, validationErr)
+	default:
+		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+	}
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(ctx *fasthttp.RequestCtx, statusCode int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.SetStatusCode(statusCode)
+	ctx.SetContentType(ContentTypeJson)
+	ctx.SetBody(body)
+}